@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"floe/runtime"
+)
+
+var answerCmd = &cobra.Command{
+	Use:   "answer [run_dir|state_file] [step_id] [value]",
+	Short: "Answer a human step of a paused run",
+	Long: `Answer a human step of a run that is waiting for input.
+The run is given by its run directory or its state file.
+The running process picks the answer up; if it has exited, continue the run with 'floe resume'.
+Approval steps accept approve or reject; choice steps accept one of their options.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		statePath, stepID, value := stateArg(args[0]), args[1], args[2]
+
+		if err := runtime.WriteAnswer(statePath, stepID, value); err != nil {
+			log.Fatalf("Failed to answer step: %v", err)
+		}
+		fmt.Printf("Answer recorded for step %s.\n", stepID)
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [run_dir|state_file]",
+	Short: "Resume a run that was persisted while waiting for human input",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts, cleanup := runtimeOptions(cmd)
+		rt, err := runtime.ResumeRuntime(stateArg(args[0]), opts...)
+		if err != nil {
+			cleanup()
+			log.Fatalf("Failed to resume run: %v", err)
+		}
+		stopMetrics, err := startMetrics(cmd, rt.Logger())
+		if err != nil {
+			cleanup()
+			log.Fatalf("Failed to start metrics server: %v", err)
+		}
+		err = rt.Run()
+		stopMetrics()
+		cleanup()
+		if err != nil {
+			log.Fatalf("Workflow execution failed: %v", err)
+		}
+	},
+}
+
+// stateArg returns a state file, or the state file inside a run directory.
+func stateArg(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, runtime.StateFileName)
+	}
+	return path
+}
+
+func init() {
+	rootCmd.AddCommand(answerCmd)
+	rootCmd.AddCommand(resumeCmd)
+	addRuntimeFlags(resumeCmd)
+}
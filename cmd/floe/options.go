@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"floe/internal/metrics"
+	"floe/internal/runtime_integration"
+	"floe/runtime"
+)
+
+// addRuntimeFlags registers the flags shared by commands that run a workflow.
+func addRuntimeFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("no-cache", false, "Ignore step result caches and always run the tools")
+	cmd.Flags().String("runs-dir", "runs", "Directory for per-run traces, event logs and artifacts")
+	cmd.Flags().String("trace-out", "", "Write the trace to this path instead of the run directory")
+	cmd.Flags().String("events-out", "", "Also stream every event as JSON Lines to this file")
+	cmd.Flags().String("otlp-file", "", "Write the run's spans as OTLP/JSON to this file")
+	cmd.Flags().String("otlp-endpoint", "", "Send the run's spans to this OTLP HTTP endpoint (e.g. http://localhost:4318)")
+	cmd.Flags().String("metrics-addr", "", "Serve Prometheus metrics on this address (e.g. :9090) while the command runs")
+	cmd.Flags().String("log-format", "text", "Log format: text or json")
+	cmd.Flags().String("log-level", "info", "Minimum log level: debug, info, warn or error")
+}
+
+// runtimeOptions converts the shared flags into runtime options.
+// The returned cleanup closes resources such as event sinks once the run ends.
+func runtimeOptions(cmd *cobra.Command) ([]runtime.Option, func()) {
+	opts := []runtime.Option{runtime.WithLogger(newLogger(cmd, os.Stderr))}
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		opts = append(opts, runtime.WithCacheDir(""))
+	}
+	if dir, _ := cmd.Flags().GetString("runs-dir"); dir != "" {
+		opts = append(opts, runtime.WithRunsDir(dir))
+	}
+	if path, _ := cmd.Flags().GetString("trace-out"); path != "" {
+		opts = append(opts, runtime.WithTraceOut(path))
+	}
+	if path, _ := cmd.Flags().GetString("otlp-file"); path != "" {
+		opts = append(opts, runtime.WithOTLPFile(path))
+	}
+	if endpoint, _ := cmd.Flags().GetString("otlp-endpoint"); endpoint != "" {
+		opts = append(opts, runtime.WithOTLPEndpoint(endpoint))
+	}
+	cleanup := func() {}
+	if path, _ := cmd.Flags().GetString("events-out"); path != "" {
+		sink, err := runtime_integration.NewJSONLFileSink(path)
+		if err != nil {
+			log.Fatalf("Failed to open event log: %v", err)
+		}
+		opts = append(opts, runtime.WithEventSink(sink))
+		cleanup = func() { _ = sink.Close() }
+	}
+	return opts, cleanup
+}
+
+// startMetrics serves /metrics when --metrics-addr is set and returns a
+// function that stops the server. Messages go to logger rather than the
+// terminal, which the TUI owns.
+func startMetrics(cmd *cobra.Command, logger *slog.Logger) (func(), error) {
+	addr, _ := cmd.Flags().GetString("metrics-addr")
+	if addr == "" {
+		return func() {}, nil
+	}
+	return serveMetrics(addr, logger)
+}
+
+// newLogger builds the runtime logger from --log-format and --log-level.
+func newLogger(cmd *cobra.Command, w io.Writer) *slog.Logger {
+	format, _ := cmd.Flags().GetString("log-format")
+	levelName, _ := cmd.Flags().GetString("log-level")
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		log.Fatalf("Invalid --log-level %q: use debug, info, warn or error", levelName)
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, handlerOpts))
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts))
+	default:
+		log.Fatalf("Invalid --log-format %q: use text or json", format)
+		return nil
+	}
+}
+
+// serveMetrics exposes the default metrics registry at /metrics and returns
+// a function that stops the server.
+func serveMetrics(addr string, logger *slog.Logger) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default.Handler())
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("metrics server stopped", "error", err)
+		}
+	}()
+	logger.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", ln.Addr()))
+	return func() { _ = srv.Close() }, nil
+}
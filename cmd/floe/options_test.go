@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestServeMetricsLogsThroughLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil)).With("run_id", "r1")
+	stop, err := serveMetrics("127.0.0.1:0", logger)
+	if err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	defer stop()
+
+	var record struct {
+		Msg   string `json:"msg"`
+		URL   string `json:"url"`
+		RunID string `json:"run_id"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log output %q is not one JSON record: %v", buf.String(), err)
+	}
+	if record.Msg != "serving metrics" || record.RunID != "r1" || record.URL == "" {
+		t.Fatalf("log record = %+v", record)
+	}
+
+	resp, err := http.Get(record.URL)
+	if err != nil {
+		t.Fatalf("scrape %s: %v", record.URL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("scrape status = %d", resp.StatusCode)
+	}
+}
+
+func TestServeMetricsReportsListenErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if _, err := serveMetrics(ln.Addr().String(), slog.Default()); err == nil {
+		t.Error("serving on a port in use succeeded")
+	}
+}
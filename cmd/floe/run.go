@@ -15,6 +15,7 @@ var runCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		filename := args[0]
+		statePath, _ := cmd.Flags().GetString("state")
 
 		// 1. Parse DSL
 		workflow, err := dsl.ParseWorkflow(filename)
@@ -23,10 +24,22 @@ var runCmd = &cobra.Command{
 		}
 
 		// 2. Initialize Runtime
-		rt := runtime.NewRuntime(workflow)
+		opts, cleanup := runtimeOptions(cmd)
+		if statePath != "" {
+			opts = append(opts, runtime.WithStateFile(statePath))
+		}
+		rt := runtime.NewRuntime(workflow, opts...)
+		stopMetrics, err := startMetrics(cmd, rt.Logger())
+		if err != nil {
+			cleanup()
+			log.Fatalf("Failed to start metrics server: %v", err)
+		}
 
 		// 3. Run Workflow
-		if err := rt.Run(); err != nil {
+		err = rt.Run()
+		stopMetrics()
+		cleanup()
+		if err != nil {
 			log.Fatalf("Workflow execution failed: %v", err)
 		}
 	},
@@ -34,4 +47,6 @@ var runCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().String("state", "", "Path of the state file written while waiting for human input (default: state.json in the run directory)")
+	addRuntimeFlags(runCmd)
 }
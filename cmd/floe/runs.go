@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"floe/runtime"
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs [workflow_name]",
+	Short: "List past runs from the run index",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("runs-dir")
+		limit, _ := cmd.Flags().GetInt("limit")
+		workflow := ""
+		if len(args) == 1 {
+			workflow = args[0]
+		}
+
+		records, err := runtime.ListRuns(dir, workflow)
+		if err != nil {
+			log.Fatalf("Failed to read run index: %v", err)
+		}
+		if len(records) == 0 {
+			fmt.Println("No runs recorded.")
+			return
+		}
+		if limit > 0 && len(records) > limit {
+			records = records[len(records)-limit:]
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RUN ID\tWORKFLOW\tSTATUS\tSTARTED\tDURATION\tTRACE")
+		for i := len(records) - 1; i >= 0; i-- {
+			rec := records[i]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				rec.RunID, rec.Workflow, rec.Status,
+				rec.StartedAt.Local().Format(time.DateTime),
+				rec.EndedAt.Sub(rec.StartedAt).Round(time.Millisecond),
+				rec.Trace)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.Flags().String("runs-dir", "runs", "Directory holding the run index")
+	runsCmd.Flags().Int("limit", 20, "Maximum number of runs to show, newest first (0 for all)")
+}
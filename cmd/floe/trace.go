@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"floe/runtime"
+)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Inspect recorded run traces",
+}
+
+var traceDiffCmd = &cobra.Command{
+	Use:   "diff [a] [b]",
+	Short: "Compare two runs step by step",
+	Long: "Compare two traces, lining steps up by name and iteration, and report differences in\n" +
+		"status, condition results, routing decisions, outputs and timings.\n" +
+		"Each argument is a trace file or a run directory containing trace.json.",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		a := loadTraceArg(args[0])
+		b := loadTraceArg(args[1])
+		diff := runtime.DiffTraces(a, b)
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(diff); err != nil {
+				log.Fatalf("Failed to encode diff: %v", err)
+			}
+			return
+		}
+		all, _ := cmd.Flags().GetBool("all")
+		printTraceDiff(diff, args[0], args[1], all)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(traceCmd)
+	traceCmd.AddCommand(traceDiffCmd)
+	traceDiffCmd.Flags().Bool("json", false, "Print the diff as JSON")
+	traceDiffCmd.Flags().Bool("all", false, "Also list steps that behaved the same")
+}
+
+// loadTraceArg loads a trace file, or the trace.json inside a run directory.
+func loadTraceArg(path string) *runtime.Trace {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "trace.json")
+	}
+	t, err := runtime.LoadTrace(path)
+	if err != nil {
+		log.Fatalf("Failed to load trace: %v", err)
+	}
+	return t
+}
+
+func printTraceDiff(d *runtime.TraceDiff, pathA, pathB string, all bool) {
+	fmt.Printf("A: %s\n", describeRun(d.A, pathA))
+	fmt.Printf("B: %s\n", describeRun(d.B, pathB))
+	for _, f := range d.Run {
+		fmt.Printf("   %s: %v → %v\n", f.Field, formatValue(f.A), formatValue(f.B))
+	}
+
+	if !d.Changed() {
+		fmt.Println("\nNo behavioural differences.")
+	} else if d.Diverged != "" {
+		fmt.Printf("\nFirst divergence: %s\n", d.Diverged)
+	}
+	fmt.Println()
+
+	for _, s := range d.Steps {
+		switch s.Change {
+		case "only_a":
+			fmt.Printf("- %-28s only in A (%s)\n", s.Label(), formatMs(s.DurationMsA))
+		case "only_b":
+			fmt.Printf("+ %-28s only in B (%s)\n", s.Label(), formatMs(s.DurationMsB))
+		case "changed":
+			fmt.Printf("~ %-28s %s\n", s.Label(), formatTiming(s.DurationMsA, s.DurationMsB))
+			for _, f := range s.Fields {
+				if f.TextDiff != nil {
+					fmt.Printf("    %s:\n", f.Field)
+					for _, line := range f.TextDiff {
+						fmt.Printf("      %s\n", line)
+					}
+					continue
+				}
+				fmt.Printf("    %s: %v → %v\n", f.Field, formatValue(f.A), formatValue(f.B))
+			}
+		default:
+			if all {
+				fmt.Printf("= %-28s %s\n", s.Label(), formatTiming(s.DurationMsA, s.DurationMsB))
+			}
+		}
+	}
+}
+
+func describeRun(s runtime.RunSummary, path string) string {
+	desc := path
+	if s.RunID != "" {
+		desc = fmt.Sprintf("%s (run %s)", path, s.RunID)
+	}
+	if s.Status != "" {
+		desc += " " + s.Status
+	}
+	return desc + " in " + formatMs(s.DurationMs)
+}
+
+func formatTiming(a, b int64) string {
+	return fmt.Sprintf("%s → %s (%+dms)", formatMs(a), formatMs(b), b-a)
+}
+
+func formatMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+// formatValue renders a field value on one line; missing values show as "-".
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprintf("%v", v)
+}
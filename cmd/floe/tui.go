@@ -1,7 +1,9 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"path/filepath"
 
 	"github.com/charmbracelet/huh"
@@ -15,14 +17,21 @@ import (
 var tuiCmd = &cobra.Command{
 	Use:   "tui",
 	Short: "Run workflow with Terminal User Interface",
-	Run: func(cmd *cobra.Command, args []string) {
+	// Errors are returned rather than fatal so deferred cleanup still runs
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		file, _ := cmd.Flags().GetString("file")
+		tracePath, _ := cmd.Flags().GetString("trace")
+
+		if tracePath != "" {
+			return replayTrace(tracePath, file)
+		}
 
 		if file == "" {
 			// Interactive selection
 			files, err := filepath.Glob("*.yaml")
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 			if len(files) == 0 {
 				// Try example directory
@@ -30,7 +39,7 @@ var tuiCmd = &cobra.Command{
 			}
 
 			if len(files) == 0 {
-				log.Fatal("No YAML files found in current or example directory")
+				return errors.New("no YAML files found in current or example directory")
 			}
 
 			form := huh.NewForm(
@@ -43,28 +52,63 @@ var tuiCmd = &cobra.Command{
 			)
 
 			if err := form.Run(); err != nil {
-				log.Fatal(err)
+				return err
 			}
 		}
 
 		// 1. Parse DSL
 		workflow, err := dsl.ParseWorkflow(file)
 		if err != nil {
-			log.Fatalf("Failed to parse workflow: %v", err)
+			return fmt.Errorf("failed to parse workflow: %w", err)
 		}
 
 		// 2. Initialize Runtime
-		rt := runtime.NewRuntime(workflow)
+		opts, cleanup := runtimeOptions(cmd)
+		defer cleanup()
+		// Writing logs to the terminal would corrupt the TUI; they still reach
+		// the log panel as events.
+		opts = append(opts, runtime.WithLogger(newLogger(cmd, io.Discard)))
+		rt := runtime.NewRuntime(workflow, opts...)
+		stopMetrics, err := startMetrics(cmd, rt.Logger())
+		if err != nil {
+			return fmt.Errorf("failed to start metrics server: %w", err)
+		}
+		defer stopMetrics()
 
 		// 3. Start TUI
 		app := tui.NewApp(rt)
 		if err := app.Run(); err != nil {
-			log.Fatalf("TUI failed: %v", err)
+			return fmt.Errorf("TUI failed: %w", err)
 		}
+		return nil
 	},
 }
 
+// replayTrace replays a recorded run. The workflow file is optional and only
+// used for the step list.
+func replayTrace(path, file string) error {
+	events, err := runtime.LoadReplay(path)
+	if err != nil {
+		return fmt.Errorf("failed to load trace: %w", err)
+	}
+
+	var workflow *dsl.Workflow
+	if file != "" {
+		workflow, err = dsl.ParseWorkflow(file)
+		if err != nil {
+			return fmt.Errorf("failed to parse workflow: %w", err)
+		}
+	}
+
+	if err := tui.NewReplayApp(events, workflow).Run(); err != nil {
+		return fmt.Errorf("TUI failed: %w", err)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(tuiCmd)
 	tuiCmd.Flags().StringP("file", "f", "", "Path to workflow YAML file")
+	tuiCmd.Flags().String("trace", "", "Replay a past run from its trace.json or events.jsonl")
+	addRuntimeFlags(tuiCmd)
 }
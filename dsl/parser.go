@@ -8,29 +8,128 @@ import (
 )
 
 type Workflow struct {
-	Name   string       `mapstructure:"name"`
-	Memory MemoryConfig `mapstructure:"memory"`
-	Steps  []Step       `mapstructure:"steps"`
+	Name           string            `mapstructure:"name"`
+	Memory         MemoryConfig      `mapstructure:"memory"`
+	MCPServers     []MCPServerConfig `mapstructure:"mcp_servers"`
+	Tools          ToolsConfig       `mapstructure:"tools"`
+	Policies       []PolicyConfig    `mapstructure:"policies"`
+	MaxConcurrency int               `mapstructure:"max_concurrency"` // 同时执行的任务步骤上限，0 表示不限制
+	Budget         BudgetConfig      `mapstructure:"budget"`
+	Steps          []Step            `mapstructure:"steps"`
+
+	TimeoutMs          int    `mapstructure:"timeout_ms"`           // 整个工作流的截止时间，0 表示不限制
+	SuperstepTimeoutMs int    `mapstructure:"superstep_timeout_ms"` // 单个 Superstep 的时间上限，0 表示不限制
+	OnError            string `mapstructure:"on_error"`             // 工作流失败时执行的清理/通知步骤
+	OnTimeout          string `mapstructure:"on_timeout"`           // 超时后执行的步骤，未设置时使用 on_error
+	HandlerTimeoutMs   int    `mapstructure:"handler_timeout_ms"`   // 补偿与 on_error/on_timeout 步骤各自的时间上限，默认 60000
+
+	Source string `mapstructure:"-"` // 工作流文件路径，由 ParseWorkflow 设置
 }
 
 type MemoryConfig struct {
 	Initial map[string]interface{} `mapstructure:"initial"`
 }
 
+// MCPServerConfig 声明一个通过 stdio 启动的 MCP Server。
+// 其工具会以 mcp.<name>.<tool> 的名称注册到工具表中。
+type MCPServerConfig struct {
+	Name    string   `mapstructure:"name"`    // 命名空间
+	Command string   `mapstructure:"command"` // 启动命令
+	Args    []string `mapstructure:"args"`    // 命令参数
+	Env     []string `mapstructure:"env"`     // 额外环境变量 (KEY=VALUE)
+	Dir     string   `mapstructure:"dir"`     // 工作目录
+}
+
+// ToolsConfig 定义内置工具的配置。
+type ToolsConfig struct {
+	Shell     ShellConfig     `mapstructure:"shell"`
+	Workspace WorkspaceConfig `mapstructure:"workspace"`
+}
+
+// WorkspaceConfig 定义文件工具可访问的工作区，所有路径都被限制在 root 之内。
+type WorkspaceConfig struct {
+	Root string `mapstructure:"root"` // 工作区根目录，默认为当前目录
+}
+
+// BudgetConfig 限制一次运行中工具上报的用量。超出后不再调度新的步骤，0 表示不限制。
+type BudgetConfig struct {
+	MaxTokens int64   `mapstructure:"max_tokens"` // 输入与输出 token 总数上限
+	MaxCost   float64 `mapstructure:"max_cost"`   // 费用上限，单位与工具上报的一致
+}
+
+// PolicyConfig 定义工具调用策略，按工具名或 input.url 的主机匹配。
+// 同时匹配多个策略时，所有策略都会生效。
+type PolicyConfig struct {
+	Name           string               `mapstructure:"name"`            // 策略名，默认为 tool 或 host
+	Tool           string               `mapstructure:"tool"`            // 工具名，支持通配符，如 mcp.github.*
+	Host           string               `mapstructure:"host"`            // 主机名，支持通配符，如 *.example.com
+	RateLimit      RateLimitConfig      `mapstructure:"rate_limit"`      // 令牌桶限流
+	MaxConcurrent  int                  `mapstructure:"max_concurrent"`  // 最大并发调用数
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"` // 熔断器
+}
+
+// RateLimitConfig 定义令牌桶限流。
+type RateLimitConfig struct {
+	Rate  float64 `mapstructure:"rate"`  // 每秒补充的令牌数，0 表示不限流
+	Burst int     `mapstructure:"burst"` // 桶容量，默认为 rate 向上取整
+}
+
+// CircuitBreakerConfig 定义熔断器：连续失败达到阈值后熔断，
+// 经过 reset_timeout_ms 后进入半开状态，允许少量探测调用。
+type CircuitBreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold"` // 触发熔断的连续失败次数，0 表示不启用
+	ResetTimeoutMs   int `mapstructure:"reset_timeout_ms"`  // 熔断持续时间，默认 30000
+	HalfOpenMax      int `mapstructure:"half_open_max"`     // 半开状态允许的并发探测数，默认 1
+}
+
+// ShellConfig 定义 shell 工具的配置。shell 工具会执行宿主机命令，
+// 属于不安全工具，必须显式设置 enabled 才能使用。
+type ShellConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`          // 是否启用
+	Allow          []string `mapstructure:"allow"`            // 允许执行的命令，"*" 表示全部
+	Dir            string   `mapstructure:"dir"`              // 工作目录，步骤的 dir 输入不能超出该目录
+	Env            []string `mapstructure:"env"`              // 额外环境变量 (KEY=VALUE)
+	AllowEnv       []string `mapstructure:"allow_env"`        // 步骤可通过 env 输入设置的变量名
+	Secrets        []string `mapstructure:"secrets"`          // 透传给命令的宿主机环境变量名
+	MaxOutputBytes int      `mapstructure:"max_output_bytes"` // stdout/stderr 的最大捕获字节数
+}
+
 // ErrorConfig 定义步骤的错误处理策略。
+// 配置 chain 时，错误依次交给链上的处理器：retry 用尽后进入下一个处理器，
+// fallback 就地执行 Fallback 步骤并以其输出作为本步骤输出，失败则继续下一个处理器。
 type ErrorConfig struct {
-	Strategy  string `mapstructure:"strategy"`   // 策略: retry, fail, ignore, fallback
-	Retries   int    `mapstructure:"retries"`    // 重试次数
-	DelayMs   int    `mapstructure:"delay_ms"`   // 重试延迟 (毫秒)
-	TimeoutMs int    `mapstructure:"timeout_ms"` // 超时时间 (毫秒)
-	Fallback  string `mapstructure:"fallback"`   // Fallback 步骤 ID
+	Strategy   string   `mapstructure:"strategy"`     // 策略: retry, fail, ignore, fallback
+	Retries    int      `mapstructure:"retries"`      // 重试次数
+	DelayMs    int      `mapstructure:"delay_ms"`     // 重试延迟 (毫秒)，指数退避时为初始延迟
+	TimeoutMs  int      `mapstructure:"timeout_ms"`   // 超时时间 (毫秒)
+	Fallback   string   `mapstructure:"fallback"`     // Fallback 步骤 ID
+	Backoff    string   `mapstructure:"backoff"`      // 退避策略: fixed (默认), exponential
+	Multiplier float64  `mapstructure:"multiplier"`   // 指数退避倍数，默认 2
+	MaxDelayMs int      `mapstructure:"max_delay_ms"` // 最大重试延迟 (毫秒)
+	Jitter     float64  `mapstructure:"jitter"`       // 随机抖动比例 (0-1)
+	RetryOn    []string `mapstructure:"retry_on"`     // 仅对这些错误类型重试
+	NoRetryOn  []string `mapstructure:"no_retry_on"`  // 不对这些错误类型重试
+
+	RetryNonIdempotent bool `mapstructure:"retry_non_idempotent"` // 允许重试声明为非幂等的工具
+
+	DefaultOutput interface{}            `mapstructure:"default_output"` // ignore 时写入内存的默认输出
+	Chain         []ErrorConfig          `mapstructure:"chain"`          // 按顺序执行的错误处理链
+	On            map[string]ErrorConfig `mapstructure:"on"`             // 按错误类型覆盖的处理配置
+}
+
+// HumanConfig 定义人工步骤（type: human）的提示与输入表单。
+type HumanConfig struct {
+	Prompt  string   `mapstructure:"prompt"`  // 提示信息，支持变量插值
+	Input   string   `mapstructure:"input"`   // 输入类型: approval, text, choice
+	Options []string `mapstructure:"options"` // 可选项（仅 choice 类型）
 }
 
 // Step 代表工作流中的一个步骤。
-// 它可以是一个简单的任务（Task），也可以是一个包含分支的并行步骤（Parallel）。
+// 它可以是一个简单的任务（Task）、一个包含分支的并行步骤（Parallel），
+// 也可以是等待人工输入的步骤（Human）。
 type Step struct {
 	ID       string                 `mapstructure:"id"`       // 步骤的唯一标识符
-	Type     string                 `mapstructure:"type"`     // 步骤类型：task 或 parallel
+	Type     string                 `mapstructure:"type"`     // 步骤类型：task, parallel 或 human
 	Tool     string                 `mapstructure:"tool"`     // 使用的工具名称（仅 task 类型）
 	Input    map[string]interface{} `mapstructure:"input"`    // 输入参数，支持变量插值
 	Output   string                 `mapstructure:"output"`   // 输出结果存储的内存路径
@@ -39,6 +138,16 @@ type Step struct {
 	When     string                 `mapstructure:"when"`     // 执行条件表达式
 	Messages map[string]string      `mapstructure:"messages"` // 步骤产生的消息，用于消息传递
 	Error    ErrorConfig            `mapstructure:"error"`    // 错误处理配置
+	Human    HumanConfig            `mapstructure:"human"`    // 人工输入配置（仅 human 类型）
+
+	Compensate     string      `mapstructure:"compensate"`      // 工作流失败时用于撤销本步骤副作用的步骤
+	MaxConcurrency int         `mapstructure:"max_concurrency"` // 同时执行的分支上限（仅 parallel 类型）
+	Cache          CacheConfig `mapstructure:"cache"`           // 结果缓存配置
+}
+
+// CacheConfig 定义步骤结果缓存。缓存以工具名和解析后的输入为键，保存在本地磁盘。
+type CacheConfig struct {
+	TTL string `mapstructure:"ttl"` // 缓存有效期，如 30m、1h；为空表示不缓存
 }
 
 // NextType defines the type of the Next field
@@ -101,6 +210,58 @@ func ParseWorkflow(filename string) (*Workflow, error) {
 	if err := v.UnmarshalKey("workflow", &wf); err != nil {
 		return nil, err
 	}
+	wf.Source = filename
 
+	if err := wf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid workflow '%s': %w", filename, err)
+	}
 	return &wf, nil
 }
+
+// Validate 检查步骤间的引用：错误处理中的 fallback、步骤的 compensate
+// 以及工作流的 on_error 与 on_timeout 都必须指向存在的顶层步骤。
+func (wf *Workflow) Validate() error {
+	ids := make(map[string]bool, len(wf.Steps))
+	for _, step := range wf.Steps {
+		ids[step.ID] = true
+	}
+	if wf.OnError != "" && !ids[wf.OnError] {
+		return fmt.Errorf("on_error refers to unknown step '%s'", wf.OnError)
+	}
+	if wf.OnTimeout != "" && !ids[wf.OnTimeout] {
+		return fmt.Errorf("on_timeout refers to unknown step '%s'", wf.OnTimeout)
+	}
+	var check func(steps []Step) error
+	check = func(steps []Step) error {
+		for _, step := range steps {
+			if err := checkFallbacks(step.ID, step.Error, ids); err != nil {
+				return err
+			}
+			if step.Compensate != "" && !ids[step.Compensate] {
+				return fmt.Errorf("step '%s' is compensated by unknown step '%s'", step.ID, step.Compensate)
+			}
+			if err := check(step.Branches); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(wf.Steps)
+}
+
+func checkFallbacks(stepID string, cfg ErrorConfig, ids map[string]bool) error {
+	if cfg.Fallback != "" && !ids[cfg.Fallback] {
+		return fmt.Errorf("step '%s' falls back to unknown step '%s'", stepID, cfg.Fallback)
+	}
+	for _, h := range cfg.Chain {
+		if err := checkFallbacks(stepID, h, ids); err != nil {
+			return err
+		}
+	}
+	for _, h := range cfg.On {
+		if err := checkFallbacks(stepID, h, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package dsl
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateStepReferences(t *testing.T) {
+	steps := func(extra ...Step) []Step {
+		return append([]Step{{ID: "a"}, {ID: "b"}}, extra...)
+	}
+	tests := []struct {
+		name    string
+		wf      Workflow
+		wantErr string // empty when the workflow is valid
+	}{
+		{"valid", Workflow{OnError: "b", OnTimeout: "a", Steps: steps(Step{ID: "c", Compensate: "b", Error: ErrorConfig{Fallback: "a"}})}, ""},
+		{"fallback", Workflow{Steps: steps(Step{ID: "c", Error: ErrorConfig{Fallback: "x"}})}, "falls back to unknown step 'x'"},
+		{"chain fallback", Workflow{Steps: steps(Step{ID: "c", Error: ErrorConfig{Chain: []ErrorConfig{{Fallback: "x"}}}})}, "unknown step 'x'"},
+		{"per-kind fallback", Workflow{Steps: steps(Step{ID: "c", Error: ErrorConfig{On: map[string]ErrorConfig{"timeout": {Fallback: "x"}}}})}, "unknown step 'x'"},
+		{"branch fallback", Workflow{Steps: steps(Step{ID: "p", Branches: []Step{{ID: "p1", Error: ErrorConfig{Fallback: "x"}}}})}, "step 'p1' falls back"},
+		{"on_error", Workflow{OnError: "x", Steps: steps()}, "on_error refers to unknown step 'x'"},
+		{"on_timeout", Workflow{OnTimeout: "x", Steps: steps()}, "on_timeout refers to unknown step 'x'"},
+		{"compensate", Workflow{Steps: steps(Step{ID: "c", Compensate: "x"})}, "compensated by unknown step 'x'"},
+		{"branch compensate", Workflow{Steps: steps(Step{ID: "p", Branches: []Step{{ID: "p1", Compensate: "x"}}})}, "step 'p1' is compensated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.wf.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExampleWorkflowsParse(t *testing.T) {
+	files, err := filepath.Glob("../example/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example workflows found: %v", err)
+	}
+	for _, f := range files {
+		if _, err := ParseWorkflow(f); err != nil {
+			t.Errorf("%s: %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package metrics is a small metrics registry that exposes counters, gauges
+// and histograms in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry used by the runtime unless another one is given.
+var Default = NewRegistry()
+
+// DefBuckets are histogram buckets in seconds suited to tool calls.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type kind string
+
+const (
+	kindCounter   kind = "counter"
+	kindGauge     kind = "gauge"
+	kindHistogram kind = "histogram"
+)
+
+// Registry holds metric families and renders them for scraping.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family is one metric name with all of its label combinations.
+type family struct {
+	name    string
+	help    string
+	kind    kind
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64 // Per bucket, histograms only
+	sum    float64
+	count  uint64
+}
+
+// register returns the family with this name, creating it on first use.
+// Registering the same name again returns the existing family.
+func (r *Registry) register(name, help string, k kind, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[name]; ok {
+		if f.kind != k || len(f.labels) != len(labels) {
+			panic(fmt.Sprintf("metrics: %s registered twice with different types or labels", name))
+		}
+		return f
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    k,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// with calls fn on the series for the given label values.
+func (f *family) with(values []string, fn func(*series)) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// Counter is a value that only goes up.
+type Counter struct{ f *family }
+
+// Counter registers a counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, kindCounter, nil, labels)}
+}
+
+// Inc adds one to the counter for the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the counter.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.with(values, func(s *series) { s.value += v })
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct{ f *family }
+
+// Gauge registers a gauge with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, kindGauge, nil, labels)}
+}
+
+// Add adds v to the gauge. Use a negative v to decrease it.
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.with(values, func(s *series) { s.value += v })
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.with(values, func(s *series) { s.value = v })
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct{ f *family }
+
+// Histogram registers a histogram. Buckets are upper bounds in increasing
+// order; DefBuckets is used when nil.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	return &Histogram{r.register(name, help, kindHistogram, buckets, labels)}
+}
+
+// Observe records one observation.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.with(values, func(s *series) {
+		for i, upper := range h.f.buckets {
+			if v <= upper {
+				s.counts[i]++
+			}
+		}
+		s.sum += v
+		s.count++
+	})
+}
+
+// WriteText writes all metrics in the Prometheus text format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != kindHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelSet(s.labels, "", ""), formatFloat(s.value))
+			continue
+		}
+		for i, upper := range f.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.labels, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelSet(s.labels, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelSet(s.labels, "", ""), s.count)
+	}
+}
+
+// labelSet renders {name="value",...}, optionally with one extra label.
+func (f *family) labelSet(values []string, extraName, extraValue string) string {
+	var parts []string
+	for i, name := range f.labels {
+		parts = append(parts, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+extraValue+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`).Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return strings.NewReplacer("\\", `\\`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+	srv := httptest.NewServer(reg.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type = %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestRegistryWritesTextFormat(t *testing.T) {
+	reg := NewRegistry()
+	calls := reg.Counter("test_calls_total", "Calls made.", "tool", "status")
+	calls.Inc("http", "ok")
+	calls.Add(2, "http", "ok")
+	calls.Inc("shell", "failed")
+	calls.Add(-1, "shell", "failed") // counters never go down
+
+	active := reg.Gauge("test_active", "Active runs.")
+	active.Add(3)
+	active.Add(-1)
+
+	latency := reg.Histogram("test_latency_seconds", "Latency.\nSecond line.", []float64{0.1, 1}, "tool")
+	latency.Observe(0.05, "http")
+	latency.Observe(0.5, "http")
+	latency.Observe(5, "http")
+
+	want := `# HELP test_active Active runs.
+# TYPE test_active gauge
+test_active 2
+# HELP test_calls_total Calls made.
+# TYPE test_calls_total counter
+test_calls_total{tool="http",status="ok"} 3
+test_calls_total{tool="shell",status="failed"} 1
+# HELP test_latency_seconds Latency.\nSecond line.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{tool="http",le="0.1"} 1
+test_latency_seconds_bucket{tool="http",le="1"} 2
+test_latency_seconds_bucket{tool="http",le="+Inf"} 3
+test_latency_seconds_sum{tool="http"} 5.55
+test_latency_seconds_count{tool="http"} 3
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("scraped:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistriesAreIndependent(t *testing.T) {
+	a, b := NewRegistry(), NewRegistry()
+	a.Counter("test_total", "Test.").Inc()
+	if got := scrape(t, b); got != "" {
+		t.Errorf("second registry exposes %q", got)
+	}
+	// Registering a name again returns the same metric
+	a.Counter("test_total", "Test.").Inc()
+	if got := scrape(t, a); !strings.Contains(got, "test_total 2\n") {
+		t.Errorf("scraped %q, want test_total 2", got)
+	}
+}
+
+func TestLabelValuesAreEscaped(t *testing.T) {
+	reg := NewRegistry()
+	reg.Gauge("test_gauge", "Test.", "name").Set(1, "a \"quoted\"\\path\nnext")
+	want := `test_gauge{name="a \"quoted\"\\path\nnext"} 1`
+	if got := scrape(t, reg); !strings.Contains(got, want) {
+		t.Errorf("scraped %q, want it to contain %q", got, want)
+	}
+}
+
+func TestRegistrationMismatchPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(*Registry)
+	}{
+		{"different type", func(r *Registry) { r.Gauge("test_total", "Test.", "tool") }},
+		{"different labels", func(r *Registry) { r.Counter("test_total", "Test.") }},
+		{"wrong label count", func(r *Registry) { r.Counter("test_total", "Test.", "tool").Inc() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			reg.Counter("test_total", "Test.", "tool")
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.fn(reg)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package runtime_integration
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// BackpressurePolicy decides what happens when a subscriber's buffer is full.
+type BackpressurePolicy int
+
+const (
+	// Block waits until the subscriber has room. Nothing is lost, but a slow
+	// subscriber slows down the runtime.
+	Block BackpressurePolicy = iota
+	// DropOldest discards the oldest buffered event to make room.
+	DropOldest
+	// DropNewest discards the event being published.
+	DropNewest
+)
+
+// defaultBuffer is the subscriber buffer size when none is given.
+const defaultBuffer = 100
+
+// SubscribeOptions configures a subscription.
+type SubscribeOptions struct {
+	Buffer int                // Channel capacity, defaults to 100
+	Policy BackpressurePolicy // What to do when the buffer is full
+	Types  []EventType        // Only deliver these event types (all if empty)
+	Filter func(Event) bool   // Optional extra filter
+}
+
+// Subscription is one subscriber of a Bus. Each subscription has its own
+// channel, so subscribers never take events from each other.
+type Subscription struct {
+	bus     *Bus
+	id      int
+	opts    SubscribeOptions
+	types   map[EventType]bool
+	ch      chan Event
+	done    chan struct{}
+	mu      sync.Mutex // serializes delivery so drop-oldest stays consistent
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns how many events were discarded for this subscriber.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops delivery and closes the channel. It is safe to call more than once.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.bus.remove(s.id)
+		s.mu.Lock()
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+func (s *Subscription) wants(e Event) bool {
+	if len(s.types) > 0 && !s.types[e.Type] {
+		return false
+	}
+	return s.opts.Filter == nil || s.opts.Filter(e)
+}
+
+// deliver sends e according to the backpressure policy. workflow_end is never
+// dropped: under a drop policy older events are discarded to make room for it.
+func (s *Subscription) deliver(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	policy := s.opts.Policy
+	if e.Type == EventWorkflowEnd && policy == DropNewest {
+		policy = DropOldest
+	}
+
+	switch policy {
+	case Block:
+		select {
+		case s.ch <- e:
+		case <-s.done:
+		}
+	case DropNewest:
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+			s.bus.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- e:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+				s.bus.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+// Bus fans events out to any number of independent subscribers.
+type Bus struct {
+	mu      sync.RWMutex
+	subs    map[int]*Subscription
+	nextID  int
+	dropped atomic.Uint64
+}
+
+// NewBus creates an empty event bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]*Subscription)}
+}
+
+// Subscribe registers a new subscriber.
+func (b *Bus) Subscribe(opts SubscribeOptions) *Subscription {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultBuffer
+	}
+	s := &Subscription{
+		bus:  b,
+		opts: opts,
+		ch:   make(chan Event, opts.Buffer),
+		done: make(chan struct{}),
+	}
+	if len(opts.Types) > 0 {
+		s.types = make(map[EventType]bool, len(opts.Types))
+		for _, t := range opts.Types {
+			s.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	s.id = b.nextID
+	b.subs[s.id] = s
+	return s
+}
+
+// Publish delivers e to every subscriber whose filter accepts it.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for _, s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if s.wants(e) {
+			s.deliver(e)
+		}
+	}
+}
+
+// Dropped returns the total number of events dropped across all subscribers.
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Close ends all subscriptions.
+func (b *Bus) Close() {
+	b.mu.RLock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for _, s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
+
+func (b *Bus) remove(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, id)
+}
@@ -0,0 +1,182 @@
+package runtime_integration
+
+import (
+	"testing"
+	"time"
+)
+
+func logEvent(n int) Event {
+	return NewEvent(EventLog, map[string]interface{}{"n": n})
+}
+
+// drain reads the events buffered in s without waiting.
+func drain(s *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case e := <-s.Events():
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func numbers(events []Event) []int {
+	var ns []int
+	for _, e := range events {
+		if n, ok := e.Payload["n"].(int); ok {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDropPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy BackpressurePolicy
+		want   []int
+	}{
+		{"drop newest", DropNewest, []int{0, 1}},
+		{"drop oldest", DropOldest, []int{3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewBus()
+			sub := bus.Subscribe(SubscribeOptions{Buffer: 2, Policy: tt.policy})
+			for i := 0; i < 5; i++ {
+				bus.Publish(logEvent(i))
+			}
+			if got := numbers(drain(sub)); !equalInts(got, tt.want) {
+				t.Errorf("received %v, want %v", got, tt.want)
+			}
+			if sub.Dropped() != 3 || bus.Dropped() != 3 {
+				t.Errorf("dropped = %d (bus %d), want 3", sub.Dropped(), bus.Dropped())
+			}
+		})
+	}
+}
+
+func TestBlockWaitsForSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(SubscribeOptions{Buffer: 1, Policy: Block})
+	const n = 20
+
+	received := make(chan []int)
+	go func() {
+		var ns []int
+		for e := range sub.Events() {
+			time.Sleep(time.Millisecond)
+			ns = append(ns, e.Payload["n"].(int))
+			if len(ns) == n {
+				break
+			}
+		}
+		received <- ns
+	}()
+
+	var want []int
+	for i := 0; i < n; i++ {
+		bus.Publish(logEvent(i))
+		want = append(want, i)
+	}
+	if got := <-received; !equalInts(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+	if sub.Dropped() != 0 {
+		t.Errorf("dropped = %d, want 0", sub.Dropped())
+	}
+}
+
+func TestUnsubscribeReleasesBlockedPublisher(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(SubscribeOptions{Buffer: 1, Policy: Block})
+	bus.Publish(logEvent(0))
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(logEvent(1))
+		close(published)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sub.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish still blocked after unsubscribe")
+	}
+}
+
+func TestDropCountsArePerSubscriber(t *testing.T) {
+	bus := NewBus()
+	slow := bus.Subscribe(SubscribeOptions{Buffer: 1, Policy: DropNewest})
+	roomy := bus.Subscribe(SubscribeOptions{Buffer: 10, Policy: DropNewest})
+	filtered := bus.Subscribe(SubscribeOptions{Buffer: 1, Policy: DropNewest, Types: []EventType{EventStepEnd}})
+	for i := 0; i < 5; i++ {
+		bus.Publish(logEvent(i))
+	}
+
+	if slow.Dropped() != 4 {
+		t.Errorf("slow subscriber dropped %d, want 4", slow.Dropped())
+	}
+	if roomy.Dropped() != 0 || len(drain(roomy)) != 5 {
+		t.Errorf("roomy subscriber dropped %d, want 0 and all 5 events", roomy.Dropped())
+	}
+	if filtered.Dropped() != 0 || len(drain(filtered)) != 0 {
+		t.Errorf("filtered subscriber got events it did not ask for")
+	}
+	if bus.Dropped() != 4 {
+		t.Errorf("bus dropped %d, want 4", bus.Dropped())
+	}
+}
+
+func TestWorkflowEndSurvivesBackpressure(t *testing.T) {
+	for _, policy := range []BackpressurePolicy{DropNewest, DropOldest} {
+		bus := NewBus()
+		sub := bus.Subscribe(SubscribeOptions{Buffer: 4, Policy: policy})
+
+		// A subscriber that reads slower than events are published
+		last := make(chan Event, 1)
+		go func() {
+			var e Event
+			for e = range sub.Events() {
+				time.Sleep(time.Millisecond)
+				if e.Type == EventWorkflowEnd {
+					break
+				}
+			}
+			last <- e
+		}()
+
+		for i := 0; i < 50; i++ {
+			bus.Publish(logEvent(i))
+		}
+		bus.Publish(NewEvent(EventWorkflowEnd, map[string]interface{}{"status": "success"}))
+
+		select {
+		case e := <-last:
+			if e.Type != EventWorkflowEnd {
+				t.Errorf("policy %d: last event = %s, want workflow_end", policy, e.Type)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("policy %d: workflow_end was never delivered", policy)
+		}
+		if sub.Dropped() == 0 {
+			t.Errorf("policy %d: the slow subscriber dropped nothing", policy)
+		}
+		bus.Close()
+	}
+}
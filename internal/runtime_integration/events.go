@@ -9,6 +9,7 @@ type EventType string
 const (
 	EventWorkflowStarted EventType = "workflow_started"
 	EventSuperstepStart  EventType = "superstep_start"
+	EventStepQueued      EventType = "step_queued"
 	EventStepStart       EventType = "step_start"
 	EventStepEnd         EventType = "step_end"
 	EventStepSkipped     EventType = "step_skipped"
@@ -16,6 +17,11 @@ const (
 	EventWorkflowEnd     EventType = "workflow_end"
 	EventTraceSnapshot   EventType = "trace_snapshot"
 	EventLog             EventType = "log"
+	EventHumanInput      EventType = "human_input"
+	EventHumanAnswered   EventType = "human_answered"
+	EventCompensateStart EventType = "compensation_start"
+	EventCompensateEnd   EventType = "compensation_end"
+	EventCircuitBreaker  EventType = "circuit_breaker"
 )
 
 type Event struct {
@@ -0,0 +1,108 @@
+package runtime_integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// EventSink receives every event emitted by the runtime, in order.
+// Write is called synchronously from the runtime and must be safe for
+// concurrent use.
+type EventSink interface {
+	Write(event Event) error
+	Close() error
+}
+
+// JSONLSink writes events to a file as JSON Lines, one event per line.
+// Each line is written before Write returns, so the file can be followed
+// with tail -f and survives a crash of the process. Lines are synced to disk
+// by Sync and Close rather than per event.
+type JSONLSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewJSONLFileSink opens path for appending, creating it and its parent
+// directories if needed.
+func NewJSONLFileSink(path string) (*JSONLSink, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink{file: f}, nil
+}
+
+func (s *JSONLSink) Write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		// Keep the stream complete even if a payload cannot be encoded
+		data, _ = json.Marshal(Event{
+			Type:      event.Type,
+			Timestamp: event.Timestamp,
+			Payload:   map[string]interface{}{"encode_error": err.Error()},
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("event sink is closed")
+	}
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// Sync flushes the lines written so far to disk.
+func (s *JSONLSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Sync()
+}
+
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file = nil
+	return err
+}
+
+// ReadJSONLFile reads events written by a JSONLSink. Lines that cannot be
+// decoded, such as a truncated last line after a crash, are skipped.
+func ReadJSONLFile(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Type == "" {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,92 @@
+package runtime_integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONLSinkWritesOneObjectPerLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "events.jsonl")
+	sink, err := NewJSONLFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{
+		NewEvent(EventStepStart, map[string]interface{}{"step_id": "a", "text": "line one\nline two"}),
+		NewEvent(EventLog, map[string]interface{}{"bad": func() {}}),
+		NewEvent(EventWorkflowEnd, map[string]interface{}{"status": "success"}),
+	}
+	for _, e := range events {
+		if err := sink.Write(e); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := sink.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := sink.Write(events[0]); err == nil {
+		t.Error("write after close succeeded")
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if len(lines) != len(events) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(events), data)
+	}
+	for i, line := range lines {
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v", i+1, err)
+		}
+		if e.Type != events[i].Type {
+			t.Errorf("line %d has type %s, want %s", i+1, e.Type, events[i].Type)
+		}
+	}
+
+	var bad Event
+	_ = json.Unmarshal(lines[1], &bad)
+	if _, ok := bad.Payload["encode_error"]; !ok {
+		t.Errorf("unencodable payload was written as %s", lines[1])
+	}
+}
+
+func TestJSONLSinkAppendsAndSkipsTruncatedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	for i := 0; i < 2; i++ {
+		sink, err := NewJSONLFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Write(logEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+		sink.Close()
+	}
+	// A crash can leave half a line behind
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"log","payl`)
+	f.Close()
+
+	events, err := ReadJSONLFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(events) != 2 || events[0].Payload["n"] != float64(0) || events[1].Payload["n"] != float64(1) {
+		t.Errorf("read %+v, want the two complete events in order", events)
+	}
+}
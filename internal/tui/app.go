@@ -1,11 +1,11 @@
 package tui
 
 import (
-	"fmt"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"floe/dsl"
 	"floe/internal/runtime_integration"
 	"floe/runtime"
 )
@@ -13,6 +13,10 @@ import (
 type App struct {
 	runtime *runtime.WorkflowRuntime
 	program *tea.Program
+
+	// Replay mode: recorded events and the optional workflow definition
+	events   []runtime_integration.Event
+	workflow *dsl.Workflow
 }
 
 func NewApp(rt *runtime.WorkflowRuntime) *App {
@@ -21,17 +25,35 @@ func NewApp(rt *runtime.WorkflowRuntime) *App {
 	}
 }
 
+// NewReplayApp creates an app that replays a past run instead of executing one.
+func NewReplayApp(events []runtime_integration.Event, wf *dsl.Workflow) *App {
+	return &App{
+		events:   events,
+		workflow: wf,
+	}
+}
+
 func (a *App) Run() error {
+	if a.runtime == nil {
+		a.program = tea.NewProgram(NewReplayModel(a.events, a.workflow), tea.WithAltScreen())
+		_, err := a.program.Run()
+		return err
+	}
+
+	// Subscribe before starting the runtime so no events are missed.
+	// Block keeps the view lossless; unsubscribing on exit releases the runtime.
+	sub := a.runtime.Subscribe(runtime_integration.SubscribeOptions{Policy: runtime_integration.Block})
+	defer sub.Unsubscribe()
+
 	// Start runtime in a separate goroutine
+	// Failures reach the view through workflow_end and log events; printing
+	// them here would corrupt the alt screen.
 	go func() {
-		if err := a.runtime.Run(); err != nil {
-			// Handle runtime error (maybe send an event or log)
-			fmt.Printf("Runtime error: %v\n", err)
-		}
+		_ = a.runtime.Run()
 	}()
 
 	// Initialize Bubbletea model
-	initialModel := NewModel(a.runtime)
+	initialModel := NewModel(a.runtime, sub)
 	a.program = tea.NewProgram(initialModel, tea.WithAltScreen())
 
 	if _, err := a.program.Run(); err != nil {
@@ -54,6 +76,10 @@ type EventMsg runtime_integration.Event
 
 func waitForEvent(sub <-chan runtime_integration.Event) tea.Cmd {
 	return func() tea.Msg {
-		return EventMsg(<-sub)
+		event, ok := <-sub
+		if !ok {
+			return nil
+		}
+		return EventMsg(event)
 	}
 }
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+
+	"floe/tools"
 )
 
 var (
@@ -66,12 +68,21 @@ func (m Model) renderStepList() string {
 		case "skipped":
 			statusIcon = "↷"
 			statusColor = skippedStyle
-		case "failed":
+		case "failed", "timed_out":
 			statusIcon = "✗"
 			statusColor = failedStyle
+		case "waiting":
+			statusIcon = "?"
+			statusColor = runningStyle
+		case "queued":
+			statusIcon = "◌"
+			statusColor = skippedStyle
 		}
 
 		line := fmt.Sprintf("%s %s %s", cursor, statusColor.Render(statusIcon), style.Render(step.ID))
+		if step.Cached {
+			line += skippedStyle.Render(" (cached)")
+		}
 		s.WriteString(line + "\n")
 	}
 
@@ -86,11 +97,33 @@ func (m Model) renderDetails() string {
 	s.WriteString(titleStyle.Render("Details"))
 	s.WriteString("\n\n")
 
+	if m.replay != nil {
+		s.WriteString(runningStyle.Render(m.replay.statusLine()) + "\n")
+		s.WriteString(statusStyle.Render("space play/pause · ←/→ step · +/- speed · enter jump · g/G start/end") + "\n")
+		s.WriteString(fmt.Sprintf("Workflow: %s\n\n", m.status))
+	}
+
+	if m.usage != (tools.Usage{}) {
+		s.WriteString(fmt.Sprintf("Run usage: %s\n\n", formatUsage(m.usage)))
+	}
+
+	if m.form != nil {
+		s.WriteString(fmt.Sprintf("Step %s needs your input\n\n", m.form.stepID))
+		s.WriteString(m.form.form.View())
+		s.WriteString("\n")
+	}
+
 	if m.selectedIdx < len(m.steps) {
 		step := m.steps[m.selectedIdx]
 		s.WriteString(fmt.Sprintf("ID: %s\n", step.ID))
 		s.WriteString(fmt.Sprintf("Tool: %s\n", step.Tool))
 		s.WriteString(fmt.Sprintf("Status: %s\n", step.Status))
+		if step.Cached {
+			s.WriteString("Cache: hit\n")
+		}
+		if step.Usage != nil {
+			s.WriteString(fmt.Sprintf("Usage: %s\n", formatUsage(*step.Usage)))
+		}
 		s.WriteString("\n--- Logs ---\n")
 
 		// Filter logs for this step (simple implementation)
@@ -107,6 +140,18 @@ func (m Model) renderDetails() string {
 		Render(s.String())
 }
 
+// formatUsage renders usage as "1200 tokens (in 1000, out 200), cost 0.012, model x".
+func formatUsage(u tools.Usage) string {
+	out := fmt.Sprintf("%d tokens (in %d, out %d)", u.TotalTokens(), u.InputTokens, u.OutputTokens)
+	if u.Cost > 0 {
+		out += fmt.Sprintf(", cost %.4g", u.Cost)
+	}
+	if u.Model != "" {
+		out += ", model " + u.Model
+	}
+	return out
+}
+
 func (m Model) renderVariables() string {
 	var s strings.Builder
 	s.WriteString(titleStyle.Render("Variables"))
@@ -1,13 +1,18 @@
 package tui
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 
 	"floe/internal/runtime_integration"
 	"floe/runtime"
+	"floe/tools"
 )
 
 type Model struct {
@@ -20,6 +25,14 @@ type Model struct {
 	logs       []string
 	variables  map[string]interface{}
 	status     string
+	usage      tools.Usage // Run total of the usage reported by steps
+
+	// Human input forms: the active one and those waiting behind it
+	form      *humanForm
+	formQueue []*humanForm
+
+	// Set when replaying a past run instead of following a live one
+	replay *replay
 
 	// UI State
 	width       int
@@ -27,13 +40,24 @@ type Model struct {
 	selectedIdx int
 }
 
+// humanForm is the huh form shown for a human step.
+type humanForm struct {
+	stepID   string
+	prompt   string
+	form     *huh.Form
+	text     *string
+	approved *bool
+}
+
 type StepItem struct {
 	ID     string
-	Status string // pending, running, executed, skipped, failed
+	Status string // pending, queued, running, waiting, executed, skipped, failed, timed_out
 	Tool   string
+	Cached bool         // Output was served from the result cache
+	Usage  *tools.Usage // Tokens and cost reported by the step's tools
 }
 
-func NewModel(rt *runtime.WorkflowRuntime) Model {
+func NewModel(rt *runtime.WorkflowRuntime, sub *runtime_integration.Subscription) Model {
 	// Initialize steps from workflow definition
 	var steps []StepItem
 	for _, s := range rt.Workflow().Steps {
@@ -46,7 +70,7 @@ func NewModel(rt *runtime.WorkflowRuntime) Model {
 
 	return Model{
 		runtime:   rt,
-		sub:       rt.Subscribe(),
+		sub:       sub.Events(),
 		steps:     steps,
 		variables: make(map[string]interface{}),
 		status:    "Ready",
@@ -54,6 +78,9 @@ func NewModel(rt *runtime.WorkflowRuntime) Model {
 }
 
 func (m Model) Init() tea.Cmd {
+	if m.replay != nil {
+		return tea.Batch(m.replay.schedule(), tick())
+	}
 	return tea.Batch(
 		waitForEvent(m.sub),
 		tick(),
@@ -61,8 +88,18 @@ func (m Model) Init() tea.Cmd {
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// While a human form is active it receives key input
+	if key, ok := msg.(tea.KeyMsg); ok && m.form != nil && key.String() != "ctrl+c" {
+		return m.updateForm(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.replay != nil {
+			if cmd, ok := m.replayKey(msg.String()); ok {
+				return m, cmd
+			}
+		}
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
@@ -84,19 +121,100 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tick()
 
 	case EventMsg:
-		m.handleEvent(msg)
-		return m, waitForEvent(m.sub)
+		cmd := m.handleEvent(msg)
+		return m, tea.Batch(waitForEvent(m.sub), cmd)
+
+	case replayTickMsg:
+		return m, m.replayTick(msg)
+	}
+
+	if m.form != nil {
+		return m.updateForm(msg)
 	}
 
 	return m, nil
 }
 
-func (m *Model) handleEvent(e EventMsg) {
+func (m Model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.form.form.Update(msg)
+	if f, ok := model.(*huh.Form); ok {
+		m.form.form = f
+	}
+
+	switch m.form.form.State {
+	case huh.StateCompleted:
+		value := *m.form.text
+		if m.form.approved != nil {
+			value = "reject"
+			if *m.form.approved {
+				value = "approve"
+			}
+		}
+		if err := m.runtime.Answer(m.form.stepID, value); err != nil {
+			m.logs = append(m.logs, fmt.Sprintf("[ERROR] Step %s: %v", m.form.stepID, err))
+		}
+		return m, tea.Batch(cmd, m.nextForm())
+	case huh.StateAborted:
+		return m, tea.Batch(cmd, m.nextForm())
+	}
+	return m, cmd
+}
+
+// nextForm activates the next queued form, if any.
+func (m *Model) nextForm() tea.Cmd {
+	m.form = nil
+	if len(m.formQueue) == 0 {
+		return nil
+	}
+	m.form, m.formQueue = m.formQueue[0], m.formQueue[1:]
+	return m.form.form.Init()
+}
+
+// newHumanForm builds the form for a human_input event.
+func newHumanForm(e EventMsg) *humanForm {
+	hf := &humanForm{
+		stepID: fmt.Sprintf("%v", e.Payload["step_id"]),
+		prompt: fmt.Sprintf("%v", e.Payload["prompt"]),
+		text:   new(string),
+	}
+
+	var field huh.Field
+	switch e.Payload["input"] {
+	case "text":
+		field = huh.NewText().Title(hf.prompt).Value(hf.text)
+	case "choice":
+		options := decodeOptions(e.Payload["options"])
+		field = huh.NewSelect[string]().
+			Title(hf.prompt).
+			Options(huh.NewOptions(options...)...).
+			Value(hf.text)
+	default:
+		hf.approved = new(bool)
+		field = huh.NewConfirm().
+			Title(hf.prompt).
+			Affirmative("Approve").
+			Negative("Reject").
+			Value(hf.approved)
+	}
+
+	hf.form = huh.NewForm(huh.NewGroup(field)).WithShowHelp(false)
+	return hf
+}
+
+func (m *Model) handleEvent(e EventMsg) tea.Cmd {
 	switch e.Type {
 	case runtime_integration.EventWorkflowStarted:
 		m.status = "Running"
 	case runtime_integration.EventWorkflowEnd:
 		m.status = "Completed"
+		switch e.Payload["status"] {
+		case "failed":
+			m.status = "Failed"
+		case "timed_out":
+			m.status = "Timed out"
+		case "budget_exceeded":
+			m.status = "Budget exceeded"
+		}
 	case runtime_integration.EventStepStart:
 		id := e.Payload["step_id"].(string)
 		m.updateStepStatus(id, "running")
@@ -105,9 +223,20 @@ func (m *Model) handleEvent(e EventMsg) {
 		id := e.Payload["step_id"].(string)
 		status := e.Payload["status"].(string)
 		m.updateStepStatus(id, status)
+		if u, ok := decodeUsage(e.Payload["usage"]); ok {
+			m.setUsage(id, u)
+			m.usage = m.usage.Add(u)
+		}
+		if hit, _ := e.Payload["cache_hit"].(bool); hit {
+			m.markCached(id)
+			m.logs = append(m.logs, fmt.Sprintf("[CACHE] Step %s served from cache", id))
+		}
 		if e.Payload["error"] != "" {
 			m.logs = append(m.logs, fmt.Sprintf("[ERROR] Step %s: %s", id, e.Payload["error"]))
 		}
+	case runtime_integration.EventStepQueued:
+		id := e.Payload["step_id"].(string)
+		m.updateStepStatus(id, "queued")
 	case runtime_integration.EventStepSkipped:
 		id := e.Payload["step_id"].(string)
 		m.updateStepStatus(id, "skipped")
@@ -115,7 +244,52 @@ func (m *Model) handleEvent(e EventMsg) {
 		key := e.Payload["key"].(string)
 		val := e.Payload["value"]
 		m.variables[key] = val
+	case runtime_integration.EventHumanInput:
+		hf := newHumanForm(e)
+		m.updateStepStatus(hf.stepID, "waiting")
+		m.logs = append(m.logs, fmt.Sprintf("[INPUT] Step %s: %s", hf.stepID, hf.prompt))
+		if m.replay != nil {
+			// Recorded answers follow as human_answered events
+			return nil
+		}
+		if m.form != nil {
+			m.formQueue = append(m.formQueue, hf)
+			return nil
+		}
+		m.form = hf
+		return hf.form.Init()
+	case runtime_integration.EventLog:
+		m.logs = append(m.logs, formatLog(e.Payload))
+	case runtime_integration.EventHumanAnswered:
+		id := e.Payload["step_id"].(string)
+		m.updateStepStatus(id, "running")
+		m.logs = append(m.logs, fmt.Sprintf("[INPUT] Step %s answered: %v", id, e.Payload["answer"]))
 	}
+	return nil
+}
+
+// formatLog renders a log event as "[LEVEL] Step id: message key=value ...".
+func formatLog(payload map[string]interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%v] ", payload["level"])
+	if id, ok := payload["step_id"]; ok {
+		fmt.Fprintf(&b, "Step %v: ", id)
+	}
+	fmt.Fprintf(&b, "%v", payload["message"])
+
+	keys := make([]string, 0, len(payload))
+	for k := range payload {
+		switch k {
+		case "level", "message", "step_id", "run_id", "superstep", "workflow":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, payload[k])
+	}
+	return b.String()
 }
 
 func (m *Model) updateStepStatus(id, status string) {
@@ -127,6 +301,63 @@ func (m *Model) updateStepStatus(id, status string) {
 	}
 }
 
+func (m *Model) setUsage(id string, u tools.Usage) {
+	for i, s := range m.steps {
+		if s.ID == id {
+			m.steps[i].Usage = &u
+			break
+		}
+	}
+}
+
+// decodeUsage reads usage from an event payload. Live events carry
+// *tools.Usage; events loaded from a file carry the decoded JSON.
+func decodeUsage(v interface{}) (tools.Usage, bool) {
+	switch u := v.(type) {
+	case nil:
+		return tools.Usage{}, false
+	case *tools.Usage:
+		if u == nil {
+			return tools.Usage{}, false
+		}
+		return *u, true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return tools.Usage{}, false
+	}
+	var u tools.Usage
+	if err := json.Unmarshal(data, &u); err != nil {
+		return tools.Usage{}, false
+	}
+	return u, true
+}
+
+// decodeOptions reads choice options from an event payload. Live events
+// carry []string; events loaded from a file carry []interface{}.
+func decodeOptions(v interface{}) []string {
+	switch opts := v.(type) {
+	case []string:
+		return opts
+	case []interface{}:
+		out := make([]string, 0, len(opts))
+		for _, o := range opts {
+			out = append(out, fmt.Sprintf("%v", o))
+		}
+		return out
+	}
+	return nil
+}
+
+func (m *Model) markCached(id string) {
+	for i, s := range m.steps {
+		if s.ID == id {
+			m.steps[i].Cached = true
+			break
+		}
+	}
+}
+
 func (m Model) View() string {
 	if m.width == 0 {
 		return "Loading..."
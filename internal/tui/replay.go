@@ -0,0 +1,204 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"floe/dsl"
+	"floe/internal/runtime_integration"
+	"floe/tools"
+)
+
+const (
+	minReplaySpeed = 0.25
+	maxReplaySpeed = 16
+	// Long pauses in the recording (waiting for a human, slow tools) are
+	// shortened so playback keeps moving.
+	maxReplayGap = time.Second
+)
+
+// replay plays back recorded events through the same Model used for live runs.
+type replay struct {
+	events  []runtime_integration.Event
+	steps   []StepItem // Step list before any event is applied
+	pos     int        // Number of events applied
+	playing bool
+	speed   float64
+	gen     int // Invalidates ticks scheduled before the last pause or seek
+}
+
+// replayTickMsg applies the next event during playback.
+type replayTickMsg struct{ gen int }
+
+// NewReplayModel creates a model that replays events. The step list comes
+// from wf when given, otherwise from the step IDs found in the events.
+func NewReplayModel(events []runtime_integration.Event, wf *dsl.Workflow) Model {
+	var steps []StepItem
+	if wf != nil {
+		for _, s := range wf.Steps {
+			steps = append(steps, StepItem{ID: s.ID, Status: "pending", Tool: s.Tool})
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, e := range events {
+			id, _ := e.Payload["step_id"].(string)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			tool, _ := e.Payload["tool"].(string)
+			steps = append(steps, StepItem{ID: id, Status: "pending", Tool: tool})
+		}
+	}
+
+	m := Model{
+		replay: &replay{
+			events:  events,
+			steps:   steps,
+			playing: true,
+			speed:   1,
+		},
+	}
+	m.seek(0)
+	return m
+}
+
+// schedule returns the tick that applies the next event, timed from the
+// recorded gap between events and the playback speed.
+func (r *replay) schedule() tea.Cmd {
+	if !r.playing || r.pos >= len(r.events) {
+		return nil
+	}
+	var gap time.Duration
+	if r.pos > 0 {
+		gap = r.events[r.pos].Timestamp.Sub(r.events[r.pos-1].Timestamp)
+	}
+	if gap > maxReplayGap {
+		gap = maxReplayGap
+	}
+	if gap < 0 {
+		gap = 0
+	}
+	delay := time.Duration(float64(gap) / r.speed)
+	if delay < time.Millisecond {
+		delay = time.Millisecond
+	}
+	gen := r.gen
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return replayTickMsg{gen: gen}
+	})
+}
+
+func (m *Model) replayTick(msg replayTickMsg) tea.Cmd {
+	r := m.replay
+	if r == nil || msg.gen != r.gen || !r.playing {
+		return nil
+	}
+	m.stepForward()
+	if r.pos >= len(r.events) {
+		r.playing = false
+		return nil
+	}
+	return r.schedule()
+}
+
+// replayKey handles playback controls. It reports false for keys it does not use.
+func (m *Model) replayKey(key string) (tea.Cmd, bool) {
+	r := m.replay
+	switch key {
+	case " ", "p":
+		r.playing = !r.playing
+		if r.playing && r.pos >= len(r.events) {
+			m.seek(0)
+		}
+		r.gen++
+		return r.schedule(), true
+	case "right", "l":
+		m.pause()
+		m.stepForward()
+	case "left", "h":
+		m.pause()
+		if r.pos > 0 {
+			m.seek(r.pos - 1)
+		}
+	case "+", "=":
+		if r.speed < maxReplaySpeed {
+			r.speed *= 2
+		}
+	case "-", "_":
+		if r.speed > minReplaySpeed {
+			r.speed /= 2
+		}
+	case "home", "g":
+		m.pause()
+		m.seek(0)
+	case "end", "G":
+		m.pause()
+		m.seek(len(r.events))
+	case "enter":
+		if m.selectedIdx < len(m.steps) {
+			m.pause()
+			m.seek(r.stepEnd(m.steps[m.selectedIdx].ID))
+		}
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+func (m *Model) pause() {
+	m.replay.playing = false
+	m.replay.gen++
+}
+
+func (m *Model) stepForward() {
+	r := m.replay
+	if r.pos < len(r.events) {
+		m.handleEvent(EventMsg(r.events[r.pos]))
+		r.pos++
+	}
+}
+
+// seek rebuilds the view from scratch with the first pos events applied.
+func (m *Model) seek(pos int) {
+	r := m.replay
+	m.steps = make([]StepItem, len(r.steps))
+	copy(m.steps, r.steps)
+	m.activeStep = ""
+	m.logs = nil
+	m.variables = make(map[string]interface{})
+	m.status = "Ready"
+	m.usage = tools.Usage{}
+
+	r.pos = 0
+	for r.pos < pos && r.pos < len(r.events) {
+		m.stepForward()
+	}
+}
+
+// stepEnd returns the position just after the first step_end of id, or after
+// its last recorded event if it never ended.
+func (r *replay) stepEnd(id string) int {
+	last := r.pos
+	for i, e := range r.events {
+		if e.Payload["step_id"] != id {
+			continue
+		}
+		if e.Type == runtime_integration.EventStepEnd {
+			return i + 1
+		}
+		last = i + 1
+	}
+	return last
+}
+
+// statusLine describes the playback position and speed.
+func (r *replay) statusLine() string {
+	state := "⏸"
+	if r.playing {
+		state = "▶"
+	}
+	return fmt.Sprintf("Replay %s %d/%d  %gx", state, r.pos, len(r.events), r.speed)
+}
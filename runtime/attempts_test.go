@@ -0,0 +1,114 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+// sequenceTool returns the errors in errs for its first calls, then succeeds.
+type sequenceTool struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (s *sequenceTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return nil, s.errs[s.calls-1]
+	}
+	return "ok", nil
+}
+
+func TestAttemptsAreRecordedPerRetry(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "attempts",
+		Steps: []dsl.Step{{
+			ID: "call", Type: "task", Tool: "seq",
+			Error: dsl.ErrorConfig{Strategy: "retry", Retries: 3, DelayMs: 5, Backoff: "exponential"},
+		}},
+	}
+	r := newTestRuntime(t, wf)
+	r.tools["seq"] = &sequenceTool{errs: []error{
+		tools.Retryable(errors.New("busy")),
+		errors.New("broken"),
+	}}
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	want := []AttemptTrace{
+		{Attempt: 1, Error: "busy", ErrorKind: ErrorKindRetryable, DelayMs: 5},
+		{Attempt: 2, Error: "broken", ErrorKind: ErrorKindUnknown, DelayMs: 10},
+		{Attempt: 3},
+	}
+	step := traceStep(r, "call")
+	if step == nil || len(step.Attempts) != len(want) {
+		t.Fatalf("step trace = %+v, want %d attempts", step, len(want))
+	}
+	if step.Retries != 2 {
+		t.Errorf("retries = %d, want 2", step.Retries)
+	}
+	for i, got := range step.Attempts {
+		w := want[i]
+		if got.Attempt != w.Attempt || got.Error != w.Error || got.ErrorKind != w.ErrorKind || got.DelayMs != w.DelayMs {
+			t.Errorf("attempt %d = %+v, want %+v", i+1, got, w)
+		}
+		if got.Tool == nil || got.Tool.StartedAt.Before(got.StartedAt) || got.Tool.EndedAt.Before(got.Tool.StartedAt) {
+			t.Errorf("attempt %d tool call = %+v", i+1, got.Tool)
+		}
+		// Each attempt starts after the previous one and its delay
+		if i > 0 {
+			prev := step.Attempts[i-1]
+			if got.StartedAt.Sub(prev.StartedAt).Milliseconds() < prev.DelayMs {
+				t.Errorf("attempt %d started %v after attempt %d, before its %dms delay",
+					i+1, got.StartedAt.Sub(prev.StartedAt), i, prev.DelayMs)
+			}
+		}
+	}
+
+	// The attempts survive the trace file
+	data, err := os.ReadFile(r.TracePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Trace
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("decode trace: %v", err)
+	}
+	if got := saved.Steps[0].Attempts; len(got) != 3 || got[1].ErrorKind != ErrorKindUnknown || got[1].DelayMs != 10 {
+		t.Errorf("saved attempts = %+v", got)
+	}
+}
+
+func TestNonRetryableErrorRecordsOneAttempt(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "attempts",
+		Steps: []dsl.Step{{
+			ID: "call", Type: "task", Tool: "seq",
+			Error: dsl.ErrorConfig{Strategy: "retry", Retries: 3, DelayMs: 5},
+		}},
+	}
+	r := newTestRuntime(t, wf)
+	r.tools["seq"] = &sequenceTool{errs: []error{tools.InvalidInput("missing field")}}
+	if err := r.Run(); err == nil {
+		t.Fatal("run succeeded")
+	}
+
+	step := traceStep(r, "call")
+	if step == nil || len(step.Attempts) != 1 {
+		t.Fatalf("step trace = %+v, want one attempt", step)
+	}
+	if a := step.Attempts[0]; a.ErrorKind != ErrorKindInvalidInput || a.DelayMs != 0 {
+		t.Errorf("attempt = %+v, want invalid_input without a delay", a)
+	}
+}
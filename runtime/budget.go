@@ -0,0 +1,25 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBudgetExceeded 表示运行的 token 或费用超出了工作流的 budget。
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// checkBudget 在每个 Superstep 结束后检查累计用量。超出时返回的错误包装 ErrBudgetExceeded，
+// 已在执行的步骤不受影响，但不会再调度新的步骤。
+func (r *WorkflowRuntime) checkBudget() error {
+	budget, usage := r.workflow.Budget, r.trace.Usage
+	if usage == nil {
+		return nil
+	}
+	if budget.MaxTokens > 0 && usage.TotalTokens() > budget.MaxTokens {
+		return fmt.Errorf("%w: used %d tokens, limit is %d", ErrBudgetExceeded, usage.TotalTokens(), budget.MaxTokens)
+	}
+	if budget.MaxCost > 0 && usage.Cost > budget.MaxCost {
+		return fmt.Errorf("%w: cost %g, limit is %g", ErrBudgetExceeded, usage.Cost, budget.MaxCost)
+	}
+	return nil
+}
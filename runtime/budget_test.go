@@ -0,0 +1,89 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+// paidTool reports a cost of 1 per call and fails when asked to.
+type paidTool struct{}
+
+func (paidTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	tools.RecordUsage(ctx, tools.Usage{Model: "m", InputTokens: 1, Cost: 1})
+	if input["fail"] == true {
+		return nil, errors.New("paid call failed")
+	}
+	return "paid", nil
+}
+
+func TestInlineFallbackUsageCountsTowardsStep(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "fallback_usage",
+		Steps: []dsl.Step{
+			{
+				ID: "main", Type: "task", Tool: "paid",
+				Input: map[string]interface{}{"fail": true},
+				Error: dsl.ErrorConfig{Chain: []dsl.ErrorConfig{{Strategy: "fallback", Fallback: "cheap"}}},
+				Next:  "done",
+			},
+			{ID: "cheap", Type: "task", Tool: "paid"},
+			{ID: "done", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "done"}},
+		},
+	}
+	r := newTestRuntime(t, wf)
+	r.tools["paid"] = paidTool{}
+	r.tools["log"] = &callLog{}
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var main *TraceEvent
+	for i := range r.trace.Steps {
+		if r.trace.Steps[i].StepName == "main" {
+			main = &r.trace.Steps[i]
+		}
+	}
+	if main == nil || main.Usage == nil || main.Usage.Cost != 2 {
+		t.Fatalf("main step usage = %+v, want the failed call and the fallback (cost 2)", main)
+	}
+	if r.trace.Usage == nil || r.trace.Usage.Cost != 2 {
+		t.Errorf("run usage = %+v, want cost 2", r.trace.Usage)
+	}
+}
+
+func TestBudgetExceededSkipsCompensationAndOnError(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name:    "budget_stop",
+		Budget:  dsl.BudgetConfig{MaxCost: 0.5},
+		OnError: "notify",
+		Steps: []dsl.Step{
+			{ID: "spend", Type: "task", Tool: "paid", Compensate: "undo", Next: "later"},
+			{ID: "later", Type: "task", Tool: "paid"},
+			{ID: "undo", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "undo"}},
+			{ID: "notify", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "notify"}},
+		},
+	}
+	r := newTestRuntime(t, wf)
+	log := &callLog{}
+	r.tools["paid"] = paidTool{}
+	r.tools["log"] = log
+
+	err := r.Run()
+	var wfErr *WorkflowError
+	if !errors.As(err, &wfErr) || !wfErr.BudgetExceeded() {
+		t.Fatalf("run error = %v, want a budget stop", err)
+	}
+	if r.trace.Status != "budget_exceeded" {
+		t.Errorf("status = %s, want budget_exceeded", r.trace.Status)
+	}
+	if len(log.steps) != 0 {
+		t.Errorf("steps run after the budget stop: %v", log.steps)
+	}
+	if wfErr.Compensation != nil {
+		t.Errorf("compensation = %+v, want none", wfErr.Compensation)
+	}
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+// defaultCacheDir 是步骤结果缓存的默认目录。
+const defaultCacheDir = ".floe/cache"
+
+// WithCacheDir 设置步骤结果缓存目录。dir 为空时禁用缓存，配置了 cache 的步骤也会正常执行。
+func WithCacheDir(dir string) Option {
+	return func(r *WorkflowRuntime) {
+		r.cache = nil
+		if dir != "" {
+			r.cache = &resultCache{dir: dir}
+		}
+	}
+}
+
+// resultCache 是按工具名与解析后的输入索引的本地磁盘缓存。
+// 读取内存的工具调用（如未指定 data 的 template）还以内存快照作为键的一部分。
+type resultCache struct {
+	dir string
+}
+
+type cacheEntry struct {
+	Tool      string           `json:"tool"`
+	Output    interface{}      `json:"output"`
+	Artifacts []tools.Artifact `json:"artifacts,omitempty"` // 调用写出的文件，命中时重新记录
+	Usage     *tools.Usage     `json:"usage,omitempty"`     // 调用的用量，命中时重新记录
+	CreatedAt time.Time        `json:"created_at"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// cacheTTL 解析步骤的缓存配置。未配置 ttl 时返回 0，表示不缓存。
+func cacheTTL(step *dsl.Step) (time.Duration, error) {
+	if step.Cache.TTL == "" || step.Type == "parallel" || step.Type == "human" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(step.Cache.TTL)
+	if err != nil || ttl <= 0 {
+		return 0, tools.InvalidInput("invalid cache ttl '%s'", step.Cache.TTL)
+	}
+	return ttl, nil
+}
+
+// key 以工具名、输入和内存快照（mem 不为 nil 时）的规范 JSON 计算缓存键。
+// encoding/json 会对 map 键排序，因此相同的输入总是得到相同的键。
+func (c *resultCache) key(tool string, input, mem map[string]interface{}) (string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(tool + "\x00"))
+	h.Write(data)
+	if mem != nil {
+		memData, err := json.Marshal(mem)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte("\x00"))
+		h.Write(memData)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *resultCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get 返回未过期的缓存结果。
+func (c *resultCache) get(tool string, input, mem map[string]interface{}) (*cacheEntry, bool) {
+	key, err := c.key(tool, input, mem)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Tool != tool {
+		return nil, false
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		_ = os.Remove(c.path(key))
+		return nil, false
+	}
+	return &entry, true
+}
+
+// put 写入缓存。无法序列化为 JSON 的输出不会被缓存。
+func (c *resultCache) put(tool string, input, mem map[string]interface{}, entry cacheEntry, ttl time.Duration) error {
+	key, err := c.key(tool, input, mem)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry.Tool = tool
+	entry.CreatedAt = now
+	entry.ExpiresAt = now.Add(ttl)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("output is not cacheable: %w", err)
+	}
+	return writeFileAtomic(c.path(key), data)
+}
+
+// cacheMemory 返回参与缓存键的内存快照；工具不读取内存时返回 nil。
+func (r *WorkflowRuntime) cacheMemory(step *dsl.Step, input map[string]interface{}) map[string]interface{} {
+	tool, err := r.tool(step.Tool)
+	if err != nil || !tools.ReadsMemory(tool, input) {
+		return nil
+	}
+	return r.memory.Snapshot()
+}
+
+// recordInto 把一次调用记录的产物与用量计入步骤的 Recorder。
+func recordInto(rec *tools.Recorder, artifacts []tools.Artifact, usage *tools.Usage) {
+	ctx := tools.WithRecorder(context.Background(), rec)
+	for _, a := range artifacts {
+		tools.RecordArtifact(ctx, a)
+	}
+	if usage != nil {
+		tools.RecordUsage(ctx, *usage)
+	}
+}
@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"floe/tools"
+)
+
+func TestResultCacheKeyIncludesMemory(t *testing.T) {
+	c := &resultCache{dir: t.TempDir()}
+	input := map[string]interface{}{"template": "hello {{.who}}"}
+	entry := cacheEntry{Output: "hello alice"}
+	alice := map[string]interface{}{"who": "alice"}
+	if err := c.put("template", input, alice, entry, time.Hour); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if got, ok := c.get("template", input, alice); !ok || got.Output != "hello alice" {
+		t.Errorf("get with the same memory = %v, %v", got, ok)
+	}
+	if _, ok := c.get("template", input, map[string]interface{}{"who": "bob"}); ok {
+		t.Error("a different memory snapshot must not hit")
+	}
+	if _, ok := c.get("template", input, nil); ok {
+		t.Error("a call that does not read memory must not share the entry")
+	}
+}
+
+func TestResultCacheReplaysArtifactsAndUsage(t *testing.T) {
+	c := &resultCache{dir: t.TempDir()}
+	input := map[string]interface{}{"prompt": "hi"}
+	entry := cacheEntry{
+		Output:    "reply",
+		Artifacts: []tools.Artifact{{Path: "out.txt", Bytes: 3}},
+		Usage:     &tools.Usage{Model: "m", InputTokens: 5, OutputTokens: 7, Cost: 0.1},
+	}
+	if err := c.put("llm", input, nil, entry, time.Hour); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	got, ok := c.get("llm", input, nil)
+	if !ok {
+		t.Fatal("expected a cache hit")
+	}
+
+	rec := &tools.Recorder{}
+	recordInto(rec, got.Artifacts, got.Usage)
+	if !reflect.DeepEqual(rec.Artifacts(), entry.Artifacts) {
+		t.Errorf("artifacts = %+v, want %+v", rec.Artifacts(), entry.Artifacts)
+	}
+	if u := rec.Usage(); u == nil || *u != *entry.Usage {
+		t.Errorf("usage = %+v, want %+v", u, entry.Usage)
+	}
+}
+
+func TestResultCacheExpires(t *testing.T) {
+	c := &resultCache{dir: t.TempDir()}
+	input := map[string]interface{}{"a": 1}
+	if err := c.put("t", input, nil, cacheEntry{Output: "x"}, time.Nanosecond); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok := c.get("t", input, nil); ok {
+		t.Error("expired entry must not hit")
+	}
+}
@@ -0,0 +1,141 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+// scriptedTool records each call by its "step" input and fails when asked
+// to, with a fatal error if "fatal" is set.
+type scriptedTool struct {
+	callLog
+}
+
+func (s *scriptedTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	s.callLog.Run(ctx, input)
+	switch {
+	case input["fatal"] == true:
+		return nil, tools.Fatal(errors.New("fatal failure"))
+	case input["fail"] == true:
+		return nil, errors.New("failure")
+	}
+	return input["step"], nil
+}
+
+func failingStep(id string, errCfg dsl.ErrorConfig, extra map[string]interface{}) dsl.Step {
+	input := map[string]interface{}{"step": id, "fail": true}
+	for k, v := range extra {
+		input[k] = v
+	}
+	return dsl.Step{ID: id, Type: "task", Tool: "script", Input: input, Error: errCfg}
+}
+
+func TestHandlerChainRunsInOrder(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "chain",
+		Steps: []dsl.Step{
+			failingStep("main", dsl.ErrorConfig{Chain: []dsl.ErrorConfig{
+				{Strategy: "retry", Retries: 2},
+				{Strategy: "fallback", Fallback: "backup"},
+				{Strategy: "ignore", DefaultOutput: "default"},
+			}}, nil),
+			failingStep("backup", dsl.ErrorConfig{}, nil),
+			{ID: "done", Type: "task", Tool: "script", Input: map[string]interface{}{"step": "done"}},
+		},
+	}
+	wf.Steps[0].Output = "global.result"
+	wf.Steps[0].Next = "done"
+	r := newTestRuntime(t, wf)
+	script := &scriptedTool{}
+	r.tools["script"] = script
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if want := []string{"main", "main", "main", "backup", "done"}; !reflect.DeepEqual(script.steps, want) {
+		t.Errorf("calls = %v, want %v", script.steps, want)
+	}
+	main := traceStep(r, "main")
+	if want := []string{"retry", "retry", "fallback:backup", "ignore"}; main == nil || !reflect.DeepEqual(main.Handlers, want) {
+		t.Errorf("handlers = %+v, want %v", main, want)
+	}
+	if v, _ := r.memory.Get("global.result"); v != "default" {
+		t.Errorf("global.result = %v, want the default output", v)
+	}
+}
+
+func TestHandlerChainPerKindOverride(t *testing.T) {
+	errCfg := dsl.ErrorConfig{
+		Strategy: "retry",
+		Retries:  2,
+		On: map[string]dsl.ErrorConfig{
+			"fatal": {Strategy: "ignore"},
+		},
+	}
+	tests := []struct {
+		name         string
+		extra        map[string]interface{}
+		wantCalls    int
+		wantHandlers []string
+		wantErr      bool
+	}{
+		{"fatal uses its override", map[string]interface{}{"fatal": true}, 1, []string{"ignore"}, false},
+		{"other kinds use the default", nil, 3, []string{"retry", "retry", "fail"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := &dsl.Workflow{Name: "on", Steps: []dsl.Step{failingStep("main", errCfg, tt.extra)}}
+			r := newTestRuntime(t, wf)
+			script := &scriptedTool{}
+			r.tools["script"] = script
+
+			if err := r.Run(); (err != nil) != tt.wantErr {
+				t.Fatalf("run error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(script.steps) != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", len(script.steps), tt.wantCalls)
+			}
+			if main := traceStep(r, "main"); main == nil || !reflect.DeepEqual(main.Handlers, tt.wantHandlers) {
+				t.Errorf("handlers = %+v, want %v", main, tt.wantHandlers)
+			}
+		})
+	}
+}
+
+func TestFallbackRunsOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		errCfg dsl.ErrorConfig
+	}{
+		{"in place within a chain", dsl.ErrorConfig{Chain: []dsl.ErrorConfig{{Strategy: "fallback", Fallback: "backup"}}}},
+		{"scheduled without a chain", dsl.ErrorConfig{Strategy: "fallback", Fallback: "backup"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := failingStep("main", tt.errCfg, nil)
+			main.Next = "done"
+			wf := &dsl.Workflow{
+				Name: "fallback_once",
+				Steps: []dsl.Step{
+					main,
+					{ID: "backup", Type: "task", Tool: "script", Input: map[string]interface{}{"step": "backup"}, Next: "done"},
+					{ID: "done", Type: "task", Tool: "script", Input: map[string]interface{}{"step": "done"}},
+				},
+			}
+			r := newTestRuntime(t, wf)
+			script := &scriptedTool{}
+			r.tools["script"] = script
+			if err := r.Run(); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if want := []string{"main", "backup", "done"}; !reflect.DeepEqual(script.steps, want) {
+				t.Errorf("calls = %v, want %v", script.steps, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+
+	"floe/internal/runtime_integration"
+)
+
+// CompensationResult 记录一个补偿步骤的执行结果。
+type CompensationResult struct {
+	StepID       string `json:"step_id"`      // 被补偿的步骤
+	Compensation string `json:"compensation"` // 补偿步骤
+	Status       string `json:"status"`       // compensated | failed
+	Error        string `json:"error,omitempty"`
+}
+
+// CompensationReport 汇总工作流失败后的补偿情况。
+type CompensationReport struct {
+	Status string               `json:"status"` // completed | partial | failed
+	Steps  []CompensationResult `json:"steps"`
+}
+
+// recordCompleted 按完成时间记录本轮成功完成的步骤。
+func (r *WorkflowRuntime) recordCompleted(results []StepResult) {
+	var done []StepResult
+	for _, res := range results {
+		if res.Status == "executed" && res.Err == nil {
+			done = append(done, res)
+		}
+	}
+	sort.SliceStable(done, func(i, j int) bool {
+		return done[i].EndedAt.Before(done[j].EndedAt)
+	})
+	for _, res := range done {
+		r.completed = append(r.completed, res.NodeName)
+	}
+}
+
+// compensate 按完成顺序的逆序执行已完成步骤声明的补偿步骤。
+// 单个补偿失败不会中断其余补偿。没有需要补偿的步骤时返回 nil。
+func (r *WorkflowRuntime) compensate() *CompensationReport {
+	type pending struct {
+		stepID, compensation string
+	}
+	var todo []pending
+	for i := len(r.completed) - 1; i >= 0; i-- {
+		step := r.findStepByID(r.completed[i])
+		if step != nil && step.Compensate != "" {
+			todo = append(todo, pending{step.ID, step.Compensate})
+		}
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	steps := make([]string, len(todo))
+	for i, p := range todo {
+		steps[i] = p.stepID
+	}
+	r.log.Info("compensating completed steps", "steps", len(todo))
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventCompensateStart, map[string]interface{}{
+		"steps": steps,
+	}))
+
+	report := &CompensationReport{}
+	failed := 0
+	for _, p := range todo {
+		cr := CompensationResult{StepID: p.stepID, Compensation: p.compensation, Status: "compensated"}
+		step := r.findStepByID(p.compensation)
+		if step == nil {
+			cr.Status = "failed"
+			cr.Error = fmt.Sprintf("compensation step '%s' not found", p.compensation)
+			r.log.Error("compensation failed", "step_id", p.stepID, "compensation", p.compensation, "error", cr.Error)
+		} else {
+			ctx, cancel := r.handlerContext()
+			res := r.executeSingleStep(ctx, step)
+			cancel()
+			res.Compensates = p.stepID
+			r.mergeResults([]StepResult{res}, r.executedSteps)
+			if res.Err != nil {
+				cr.Status = "failed"
+				cr.Error = res.Err.Error()
+			}
+		}
+		if cr.Status == "failed" {
+			failed++
+		}
+		report.Steps = append(report.Steps, cr)
+	}
+
+	switch {
+	case failed == 0:
+		report.Status = "completed"
+	case failed == len(todo):
+		report.Status = "failed"
+	default:
+		report.Status = "partial"
+	}
+
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventCompensateEnd, map[string]interface{}{
+		"status": report.Status,
+		"steps":  report.Steps,
+	}))
+	return report
+}
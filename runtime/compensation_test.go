@@ -0,0 +1,87 @@
+package runtime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"floe/dsl"
+)
+
+func sagaWorkflow(undoAFails bool) *dsl.Workflow {
+	step := func(id, compensate, next string, fail bool) dsl.Step {
+		return dsl.Step{
+			ID: id, Type: "task", Tool: "script", Compensate: compensate, Next: next,
+			Input: map[string]interface{}{"step": id, "fail": fail},
+		}
+	}
+	return &dsl.Workflow{
+		Name: "saga",
+		// Steps complete in the order a, c, b, then f fails
+		Steps: []dsl.Step{
+			step("a", "undo_a", "c", false),
+			step("b", "undo_b", "f", false),
+			step("c", "undo_c", "b", false),
+			step("f", "undo_f", "d", true),
+			step("d", "undo_d", "", false),
+			step("undo_a", "", "", undoAFails),
+			step("undo_b", "", "", false),
+			step("undo_c", "", "", false),
+			step("undo_d", "", "", false),
+			step("undo_f", "", "", false),
+		},
+	}
+}
+
+func TestCompensationRunsInReverseCompletionOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		undoAFails bool
+		wantStatus string
+		wantSteps  []string // compensated step and its status
+	}{
+		{"all compensated", false, "completed", []string{"b:compensated", "c:compensated", "a:compensated"}},
+		{"one compensation fails", true, "partial", []string{"b:compensated", "c:compensated", "a:failed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRuntime(t, sagaWorkflow(tt.undoAFails))
+			script := &scriptedTool{}
+			r.tools["script"] = script
+
+			err := r.Run()
+			var wfErr *WorkflowError
+			if !errors.As(err, &wfErr) || wfErr.Compensation == nil {
+				t.Fatalf("run error = %v, want a failure with compensation", err)
+			}
+			// f failed and d never ran, so neither is compensated
+			if want := []string{"a", "c", "b", "f", "undo_b", "undo_c", "undo_a"}; !reflect.DeepEqual(script.steps, want) {
+				t.Errorf("calls = %v, want %v", script.steps, want)
+			}
+
+			report := wfErr.Compensation
+			var got []string
+			for _, s := range report.Steps {
+				got = append(got, s.StepID+":"+s.Status)
+			}
+			if report.Status != tt.wantStatus || !reflect.DeepEqual(got, tt.wantSteps) {
+				t.Errorf("compensation = %s %v, want %s %v", report.Status, got, tt.wantStatus, tt.wantSteps)
+			}
+			if r.trace.Compensation == nil || r.trace.Compensation.Status != tt.wantStatus {
+				t.Errorf("trace compensation = %+v", r.trace.Compensation)
+			}
+
+			for _, undo := range []string{"undo_a", "undo_b", "undo_c"} {
+				s := traceStep(r, undo)
+				if s == nil || s.Compensates != undo[len("undo_"):] {
+					t.Errorf("trace for %s = %+v, want it to record what it compensates", undo, s)
+				}
+			}
+			for _, never := range []string{"d", "undo_d", "undo_f"} {
+				if s := traceStep(r, never); s != nil {
+					t.Errorf("%s appears in the trace: %+v", never, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package runtime
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"floe/dsl"
+	"floe/internal/runtime_integration"
+)
+
+// recordingSink keeps the order in which events were written.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []int
+}
+
+func (s *recordingSink) Write(e runtime_integration.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e.Payload["n"].(int))
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestEmitKeepsSinkAndSubscriberOrder(t *testing.T) {
+	sink := &recordingSink{}
+	r := newTestRuntime(t, &dsl.Workflow{Name: "emit"}, WithEventSink(sink))
+	const n = 200
+	sub := r.Subscribe(runtime_integration.SubscribeOptions{Buffer: n, Policy: runtime_integration.Block})
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.Emit(runtime_integration.NewEvent(runtime_integration.EventLog, map[string]interface{}{"n": i}))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, want := range sink.events {
+		got := (<-sub.Events()).Payload["n"].(int)
+		if got != want {
+			t.Fatalf("event %d: subscriber saw %d, sink saw %d", i, got, want)
+		}
+	}
+	if len(sink.events) != n {
+		t.Fatalf("sink got %d events, want %d", len(sink.events), n)
+	}
+}
+
+// syncingSink records writes by event type and the points at which it was synced.
+type syncingSink struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (s *syncingSink) Write(e runtime_integration.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, string(e.Type))
+	return nil
+}
+
+func (s *syncingSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, "sync")
+	return nil
+}
+
+func (s *syncingSink) Close() error { return nil }
+
+func TestSinksSyncAfterEachSuperstep(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "sync",
+		Steps: []dsl.Step{
+			{ID: "a", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "a"}, Next: "b"},
+			{ID: "b", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "b"}},
+		},
+	}
+	sink := &syncingSink{}
+	r := newTestRuntime(t, wf, WithEventSink(sink))
+	r.tools["log"] = &callLog{}
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// Each superstep's step events are synced before the next superstep starts
+	var syncs, stepEnds int
+	for i, entry := range sink.log {
+		switch entry {
+		case "sync":
+			syncs++
+			if syncs > stepEnds {
+				t.Errorf("sync %d at %d comes before its superstep's step_end: %v", syncs, i, sink.log)
+			}
+		case string(runtime_integration.EventStepEnd):
+			stepEnds++
+		case string(runtime_integration.EventSuperstepStart):
+			if syncs != stepEnds {
+				t.Errorf("superstep started at %d before the previous one was synced: %v", i, sink.log)
+			}
+		}
+	}
+	if syncs != 2 {
+		t.Errorf("synced %d times, want once per superstep (2): %v", syncs, sink.log)
+	}
+
+	// The run's own event log holds every event up to workflow_end and is closed
+	var written int
+	for _, entry := range sink.log {
+		if entry != "sync" {
+			written++
+		}
+		if entry == string(runtime_integration.EventWorkflowEnd) {
+			break
+		}
+	}
+	events, err := runtime_integration.ReadJSONLFile(filepath.Join(r.RunDir(), "events.jsonl"))
+	if err != nil {
+		t.Fatalf("read event log: %v", err)
+	}
+	if len(events) != written || events[len(events)-1].Type != runtime_integration.EventWorkflowEnd {
+		t.Errorf("event log has %d events ending in %s, want %d ending in workflow_end",
+			len(events), events[len(events)-1].Type, written)
+	}
+	if err := r.events.Write(events[0]); err == nil {
+		t.Error("the run's event log is still open after Run")
+	}
+}
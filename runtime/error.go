@@ -1,6 +1,17 @@
 package runtime
 
-import "floe/dsl"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"floe/dsl"
+	"floe/tools"
+)
 
 // ActionType 定义错误处理动作的类型。
 type ActionType int
@@ -18,10 +29,20 @@ type ErrorAction struct {
 	FallbackStepName string
 }
 
-// handleError 根据步骤的错误配置决定采取的动作。
-func handleError(step *dsl.Step, err error) ErrorAction {
-	config := step.Error
+// 错误类型，用于 retry_on / no_retry_on 匹配。
+const (
+	ErrorKindTimeout      = "timeout"
+	ErrorKindCanceled     = "canceled"
+	ErrorKindRateLimited  = "rate_limited"
+	ErrorKindInvalidInput = "invalid_input"
+	ErrorKindFatal        = "fatal"
+	ErrorKindRetryable    = "retryable"
+	ErrorKindCircuitOpen  = "circuit_open"
+	ErrorKindUnknown      = "error"
+)
 
+// handleError 根据单个错误处理器的配置决定采取的动作。
+func handleError(config dsl.ErrorConfig) ErrorAction {
 	switch config.Strategy {
 	case "retry":
 		return ErrorAction{Type: ActionRetry}
@@ -35,3 +56,170 @@ func handleError(step *dsl.Step, err error) ErrorAction {
 		return ErrorAction{Type: ActionFail}
 	}
 }
+
+// errorHandlers 返回某类错误对应的处理链。on 中按错误类型的覆盖优先；
+// key 标识所用的处理链，chained 表示配置来自显式的 chain。
+// 未配置 chain 时沿用单一策略：retry 配合 fallback 等价于 [retry, fallback]。
+func errorHandlers(config dsl.ErrorConfig, kind string) (handlers []dsl.ErrorConfig, chained bool, key string) {
+	if override, ok := config.On[kind]; ok {
+		config = override
+		key = kind
+	}
+	if len(config.Chain) > 0 {
+		return config.Chain, true, key
+	}
+	if config.Strategy == "retry" && config.Fallback != "" {
+		return []dsl.ErrorConfig{config, {Strategy: "fallback", Fallback: config.Fallback}}, false, key
+	}
+	return []dsl.ErrorConfig{config}, false, key
+}
+
+// classifyError 返回错误的类型。
+func classifyError(err error) string {
+	var rateLimit *tools.RateLimitError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorKindTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.As(err, &rateLimit):
+		return ErrorKindRateLimited
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrorKindCircuitOpen
+	case errors.Is(err, tools.ErrInvalidInput):
+		return ErrorKindInvalidInput
+	case errors.Is(err, tools.ErrFatal):
+		return ErrorKindFatal
+	case errors.Is(err, tools.ErrRetryable):
+		return ErrorKindRetryable
+	default:
+		return ErrorKindUnknown
+	}
+}
+
+// shouldRetry 判断某类错误是否值得重试。
+// 未配置 retry_on 时，除 invalid_input、fatal、canceled 与 circuit_open 外的错误都会重试。
+func shouldRetry(config dsl.ErrorConfig, kind string) bool {
+	if containsKind(config.NoRetryOn, kind) {
+		return false
+	}
+	if len(config.RetryOn) > 0 {
+		return containsKind(config.RetryOn, kind)
+	}
+	switch kind {
+	case ErrorKindInvalidInput, ErrorKindFatal, ErrorKindCanceled, ErrorKindCircuitOpen:
+		return false
+	}
+	return true
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay 计算第 attempt 次重试 (从 1 开始) 前的等待时间。
+// 限流错误携带的 retry-after 提示优先于计算出的延迟。
+func retryDelay(config dsl.ErrorConfig, attempt int, err error) time.Duration {
+	base := float64(config.DelayMs) * float64(time.Millisecond)
+	delay := base
+
+	if config.Backoff == "exponential" {
+		multiplier := config.Multiplier
+		if multiplier <= 1 {
+			multiplier = 2
+		}
+		delay = base * math.Pow(multiplier, float64(attempt-1))
+	}
+
+	maxDelay := float64(config.MaxDelayMs) * float64(time.Millisecond)
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if config.Jitter > 0 {
+		jitter := math.Min(config.Jitter, 1)
+		delay = delay * (1 + jitter*(2*rand.Float64()-1))
+	}
+
+	d := time.Duration(delay)
+	var rateLimit *tools.RateLimitError
+	if errors.As(err, &rateLimit) && rateLimit.RetryAfter > d {
+		d = rateLimit.RetryAfter
+	}
+	var circuitOpen *CircuitOpenError
+	if errors.As(err, &circuitOpen) && circuitOpen.RetryAfter > d {
+		d = circuitOpen.RetryAfter
+	}
+	return d
+}
+
+// StepFailure 描述一个导致工作流失败的步骤。
+type StepFailure struct {
+	StepID    string `json:"step_id"`
+	Error     string `json:"error"`
+	ErrorKind string `json:"error_kind,omitempty"`
+}
+
+// WorkflowError 表示工作流因步骤失败而终止。
+type WorkflowError struct {
+	Workflow     string
+	FailedSteps  []StepFailure
+	Compensation *CompensationReport // 未声明补偿步骤时为 nil
+	Cause        error               // 超时终止时为 ErrWorkflowTimeout 或 ErrSuperstepTimeout，超出预算时为 ErrBudgetExceeded
+}
+
+func (e *WorkflowError) Error() string {
+	parts := make([]string, len(e.FailedSteps))
+	for i, f := range e.FailedSteps {
+		parts[i] = fmt.Sprintf("%s: %s", f.StepID, f.Error)
+	}
+	msg := fmt.Sprintf("workflow '%s' failed: %s", e.Workflow, strings.Join(parts, "; "))
+	if e.TimedOut() {
+		msg = fmt.Sprintf("workflow '%s' timed out: %v", e.Workflow, e.Cause)
+		if len(parts) > 0 {
+			msg += " (" + strings.Join(parts, "; ") + ")"
+		}
+	}
+	if e.BudgetExceeded() {
+		msg = fmt.Sprintf("workflow '%s' stopped: %v", e.Workflow, e.Cause)
+	}
+	if e.Compensation != nil {
+		msg += fmt.Sprintf(" (compensation %s)", e.Compensation.Status)
+	}
+	return msg
+}
+
+func (e *WorkflowError) Unwrap() error {
+	return e.Cause
+}
+
+// TimedOut 判断工作流是否因超时而终止。
+func (e *WorkflowError) TimedOut() bool {
+	return errors.Is(e.Cause, ErrWorkflowTimeout) || errors.Is(e.Cause, ErrSuperstepTimeout)
+}
+
+// BudgetExceeded 判断工作流是否因超出预算而停止。
+func (e *WorkflowError) BudgetExceeded() bool {
+	return errors.Is(e.Cause, ErrBudgetExceeded)
+}
+
+// failedSteps 返回结果中以失败告终的步骤。被忽略或转入 Fallback 的错误不算失败。
+func failedSteps(results []StepResult) []StepFailure {
+	var failures []StepFailure
+	for _, res := range results {
+		if res.Err == nil || res.Ignored || res.Fallback != "" {
+			continue
+		}
+		failures = append(failures, StepFailure{
+			StepID:    res.NodeName,
+			Error:     res.Err.Error(),
+			ErrorKind: res.ErrorKind,
+		})
+	}
+	return failures
+}
@@ -0,0 +1,114 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, ErrorKindTimeout},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), ErrorKindTimeout},
+		{context.Canceled, ErrorKindCanceled},
+		{tools.RateLimited(time.Second, errors.New("429")), ErrorKindRateLimited},
+		{&CircuitOpenError{Policy: "api"}, ErrorKindCircuitOpen},
+		{tools.InvalidInput("bad %s", "input"), ErrorKindInvalidInput},
+		{tools.Fatal(errors.New("gone")), ErrorKindFatal},
+		{tools.Retryable(errors.New("flaky")), ErrorKindRetryable},
+		{errors.New("plain"), ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		config dsl.ErrorConfig
+		kind   string
+		want   bool
+	}{
+		{"unknown errors retry", dsl.ErrorConfig{}, ErrorKindUnknown, true},
+		{"timeouts retry", dsl.ErrorConfig{}, ErrorKindTimeout, true},
+		{"rate limits retry", dsl.ErrorConfig{}, ErrorKindRateLimited, true},
+		{"invalid input does not retry", dsl.ErrorConfig{}, ErrorKindInvalidInput, false},
+		{"fatal does not retry", dsl.ErrorConfig{}, ErrorKindFatal, false},
+		{"canceled does not retry", dsl.ErrorConfig{}, ErrorKindCanceled, false},
+		{"open circuit does not retry", dsl.ErrorConfig{}, ErrorKindCircuitOpen, false},
+		{"retry_on limits kinds", dsl.ErrorConfig{RetryOn: []string{"timeout"}}, ErrorKindUnknown, false},
+		{"retry_on matches", dsl.ErrorConfig{RetryOn: []string{"timeout"}}, ErrorKindTimeout, true},
+		{"retry_on can allow fatal", dsl.ErrorConfig{RetryOn: []string{"fatal"}}, ErrorKindFatal, true},
+		{"no_retry_on wins", dsl.ErrorConfig{RetryOn: []string{"timeout"}, NoRetryOn: []string{"timeout"}}, ErrorKindTimeout, false},
+		{"no_retry_on excludes", dsl.ErrorConfig{NoRetryOn: []string{"rate_limited"}}, ErrorKindRateLimited, false},
+	}
+	for _, tt := range tests {
+		if got := shouldRetry(tt.config, tt.kind); got != tt.want {
+			t.Errorf("%s: shouldRetry = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	ms := time.Millisecond
+	plain := errors.New("plain")
+	tests := []struct {
+		name    string
+		config  dsl.ErrorConfig
+		attempt int
+		err     error
+		want    time.Duration
+	}{
+		{"no delay", dsl.ErrorConfig{}, 1, plain, 0},
+		{"fixed", dsl.ErrorConfig{DelayMs: 100}, 3, plain, 100 * ms},
+		{"exponential first", dsl.ErrorConfig{DelayMs: 100, Backoff: "exponential"}, 1, plain, 100 * ms},
+		{"exponential doubles", dsl.ErrorConfig{DelayMs: 100, Backoff: "exponential"}, 3, plain, 400 * ms},
+		{"custom multiplier", dsl.ErrorConfig{DelayMs: 100, Backoff: "exponential", Multiplier: 3}, 3, plain, 900 * ms},
+		{"multiplier at most 1 doubles", dsl.ErrorConfig{DelayMs: 100, Backoff: "exponential", Multiplier: 1}, 2, plain, 200 * ms},
+		{"max delay caps", dsl.ErrorConfig{DelayMs: 100, Backoff: "exponential", MaxDelayMs: 250}, 5, plain, 250 * ms},
+		{"retry-after wins when longer", dsl.ErrorConfig{DelayMs: 100}, 1, tools.RateLimited(time.Second, plain), time.Second},
+		{"retry-after ignored when shorter", dsl.ErrorConfig{DelayMs: 100}, 1, tools.RateLimited(ms, plain), 100 * ms},
+		{"open circuit waits for reset", dsl.ErrorConfig{DelayMs: 100}, 1, &CircuitOpenError{RetryAfter: 2 * time.Second}, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.config, tt.attempt, tt.err); got != tt.want {
+			t.Errorf("%s: retryDelay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelayJitterBounds(t *testing.T) {
+	tests := []struct {
+		jitter   float64
+		min, max time.Duration
+	}{
+		{0.2, 80 * time.Millisecond, 120 * time.Millisecond},
+		// Jitter above 1 is treated as 1
+		{5, 0, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		config := dsl.ErrorConfig{DelayMs: 100, Jitter: tt.jitter}
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 1000; i++ {
+			d := retryDelay(config, 1, errors.New("plain"))
+			if d < tt.min || d > tt.max {
+				t.Fatalf("jitter %v: delay %v outside [%v, %v]", tt.jitter, d, tt.min, tt.max)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("jitter %v: every delay was the same", tt.jitter)
+		}
+	}
+}
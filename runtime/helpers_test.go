@@ -0,0 +1,41 @@
+package runtime
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"floe/dsl"
+)
+
+// newTestRuntime builds a runtime that keeps its run directory in a temp dir,
+// does not cache results and discards logs. opts are applied after these.
+func newTestRuntime(t *testing.T, wf *dsl.Workflow, opts ...Option) *WorkflowRuntime {
+	t.Helper()
+	return NewRuntime(wf, append(testOptions(t), opts...)...)
+}
+
+// testOptions returns the options newTestRuntime applies, for runtimes built
+// another way, such as by ResumeRuntime.
+func testOptions(t *testing.T) []Option {
+	return []Option{
+		WithRunsDir(t.TempDir()),
+		WithCacheDir(""),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}
+}
+
+// callLog records which steps ran it, by their "step" input.
+type callLog struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (l *callLog) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, input["step"].(string))
+	return nil, nil
+}
@@ -0,0 +1,461 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"floe/dsl"
+	"floe/internal/runtime_integration"
+	"floe/memory"
+)
+
+// answerPollInterval 是等待人工输入时检查答案文件的间隔。
+const answerPollInterval = 500 * time.Millisecond
+
+// HumanRequest 描述一个等待人工输入的步骤。
+type HumanRequest struct {
+	StepID  string   `json:"step_id"`
+	Prompt  string   `json:"prompt"`
+	Input   string   `json:"input"` // approval | text | choice
+	Options []string `json:"options,omitempty"`
+}
+
+type pendingHuman struct {
+	request HumanRequest
+	answer  chan string
+}
+
+// Checkpoint 是持久化的运行状态。它记录当前 Superstep 开始时的状态，
+// 恢复时会重新执行该 Superstep，本 Superstep 中已收到输入的人工步骤直接使用已有答案。
+type Checkpoint struct {
+	Workflow      string                 `json:"workflow"`
+	WorkflowName  string                 `json:"workflow_name"`
+	Memory        map[string]interface{} `json:"memory"`
+	ExecutedSteps []string               `json:"executed_steps"`
+	LastResults   []checkpointResult     `json:"last_results"`
+	Trace         *Trace                 `json:"trace"`
+	Pending       []HumanRequest         `json:"pending,omitempty"`
+	Answers       map[string]string      `json:"answers,omitempty"`
+	Completed     []string               `json:"completed,omitempty"`
+	RunID         string                 `json:"run_id"`
+	RunsDir       string                 `json:"runs_dir,omitempty"`
+	Iterations    map[string]int         `json:"iterations,omitempty"`
+	Superstep     int                    `json:"superstep"`
+	SavedAt       time.Time              `json:"saved_at"`
+}
+
+// checkpointResult 保存调度器计算后续步骤所需的 StepResult 字段。
+type checkpointResult struct {
+	NodeName string `json:"node_name"`
+	Error    string `json:"error,omitempty"`
+	Ignored  bool   `json:"ignored,omitempty"`
+	Fallback string `json:"fallback,omitempty"`
+	Status   string `json:"status"`
+}
+
+// Answer 向等待中的人工步骤提交输入。
+func (r *WorkflowRuntime) Answer(stepID, value string) error {
+	r.humanMu.Lock()
+	p, ok := r.pendingHuman[stepID]
+	if !ok {
+		r.humanMu.Unlock()
+		return fmt.Errorf("step '%s' is not waiting for input", stepID)
+	}
+	answer, err := normalizeAnswer(p.request, value)
+	if err != nil {
+		r.humanMu.Unlock()
+		return err
+	}
+	r.answers[stepID] = answer
+	delete(r.pendingHuman, stepID)
+	r.humanMu.Unlock()
+
+	p.answer <- answer
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventHumanAnswered, map[string]interface{}{
+		"step_id": stepID,
+		"answer":  answer,
+	}))
+	return nil
+}
+
+// PendingHuman 返回当前等待输入的人工步骤。
+func (r *WorkflowRuntime) PendingHuman() []HumanRequest {
+	r.humanMu.Lock()
+	defer r.humanMu.Unlock()
+	var reqs []HumanRequest
+	for _, p := range r.pendingHuman {
+		reqs = append(reqs, p.request)
+	}
+	return reqs
+}
+
+// waitForHuman 挂起人工步骤直到收到输入，答案即为步骤输出。
+func (r *WorkflowRuntime) waitForHuman(ctx context.Context, step *dsl.Step) (interface{}, error) {
+	req := HumanRequest{
+		StepID:  step.ID,
+		Prompt:  r.memory.ResolveInterpolation(step.Human.Prompt),
+		Input:   step.Human.Input,
+		Options: step.Human.Options,
+	}
+	if req.Input == "" {
+		req.Input = "approval"
+	}
+	switch req.Input {
+	case "approval", "text":
+	case "choice":
+		if len(req.Options) == 0 {
+			return nil, fmt.Errorf("human step '%s' uses input 'choice' without options", step.ID)
+		}
+	default:
+		return nil, fmt.Errorf("human step '%s' has unsupported input '%s'", step.ID, req.Input)
+	}
+
+	ch := make(chan string, 1)
+	r.humanMu.Lock()
+	if answer, ok := r.answers[step.ID]; ok {
+		r.consumeAnswer(step.ID, answer)
+		r.humanMu.Unlock()
+		return answer, nil
+	}
+	r.pendingHuman[step.ID] = &pendingHuman{request: req, answer: ch}
+	r.humanMu.Unlock()
+
+	defer func() {
+		r.humanMu.Lock()
+		delete(r.pendingHuman, step.ID)
+		r.humanMu.Unlock()
+	}()
+
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventHumanInput, map[string]interface{}{
+		"step_id": req.StepID,
+		"prompt":  req.Prompt,
+		"input":   req.Input,
+		"options": req.Options,
+	}))
+
+	var poll <-chan time.Time
+	if r.statePath != "" {
+		if err := r.saveState(); err != nil {
+			r.log.Warn("failed to save run state", "step_id", step.ID, "error", err)
+		}
+		r.log.Info("step is waiting for input", "step_id", step.ID, "prompt", req.Prompt,
+			"answer_with", fmt.Sprintf("floe answer %s %s <value>", r.statePath, step.ID))
+		// 进程可能在等待期间退出，先让已写出的事件落盘
+		r.syncSinks()
+		ticker := time.NewTicker(answerPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case answer := <-ch:
+			r.humanMu.Lock()
+			r.consumeAnswer(step.ID, answer)
+			r.humanMu.Unlock()
+			if r.statePath != "" {
+				_ = r.saveState()
+			}
+			return answer, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-poll:
+			answers, err := readAnswers(AnswersPath(r.statePath))
+			if err != nil {
+				continue
+			}
+			if value, ok := answers[step.ID]; ok {
+				if err := r.Answer(step.ID, value); err != nil {
+					r.log.Warn("invalid answer", "step_id", step.ID, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// consumeAnswer 标记人工步骤已使用该答案：答案从待用答案与答案文件中移除，
+// 同一步骤再次执行时会重新等待输入。答案仍保存在当前 Superstep 的检查点中，
+// 以便恢复运行重新执行该 Superstep 时使用。调用方需持有 humanMu。
+func (r *WorkflowRuntime) consumeAnswer(stepID, answer string) {
+	delete(r.answers, stepID)
+	if r.checkpoint != nil {
+		if r.checkpoint.Answers == nil {
+			r.checkpoint.Answers = make(map[string]string)
+		}
+		r.checkpoint.Answers[stepID] = answer
+	}
+	if r.statePath != "" {
+		if err := removeAnswer(AnswersPath(r.statePath), stepID); err != nil && !os.IsNotExist(err) {
+			r.log.Warn("failed to update answers file", "step_id", stepID, "error", err)
+		}
+	}
+}
+
+// beginCheckpoint 记录当前 Superstep 开始时的运行状态。
+func (r *WorkflowRuntime) beginCheckpoint() {
+	if r.statePath == "" {
+		return
+	}
+	executed := make([]string, 0, len(r.executedSteps))
+	for id := range r.executedSteps {
+		executed = append(executed, id)
+	}
+	last := make([]checkpointResult, 0, len(r.lastResults))
+	for _, res := range r.lastResults {
+		cr := checkpointResult{
+			NodeName: res.NodeName,
+			Ignored:  res.Ignored,
+			Fallback: res.Fallback,
+			Status:   res.Status,
+		}
+		if res.Err != nil {
+			cr.Error = res.Err.Error()
+		}
+		last = append(last, cr)
+	}
+	r.checkpoint = &Checkpoint{
+		Workflow:      r.workflow.Source,
+		WorkflowName:  r.workflow.Name,
+		Memory:        r.memory.Snapshot(),
+		ExecutedSteps: executed,
+		LastResults:   last,
+		Completed:     append([]string(nil), r.completed...),
+		RunID:         r.runID,
+		RunsDir:       r.runsDir,
+		Iterations:    r.snapshotIterations(),
+		Superstep:     r.superstep,
+		Trace: &Trace{
+			RunID:     r.trace.RunID,
+			Workflow:  r.trace.Workflow,
+			StartedAt: r.trace.StartedAt,
+			Steps:     append([]TraceEvent(nil), r.trace.Steps...),
+			Artifacts: append(r.trace.Artifacts[:0:0], r.trace.Artifacts...),
+			Usage:     r.trace.Usage,
+		},
+	}
+}
+
+// saveState 将 Superstep 开始时的状态、等待中的请求和已有答案写入状态文件。
+func (r *WorkflowRuntime) saveState() error {
+	if r.checkpoint == nil {
+		return fmt.Errorf("no checkpoint available")
+	}
+	pending := r.PendingHuman()
+	r.humanMu.Lock()
+	cp := *r.checkpoint
+	cp.Pending = pending
+	cp.Answers = make(map[string]string, len(r.checkpoint.Answers)+len(r.answers))
+	for k, v := range r.checkpoint.Answers {
+		cp.Answers[k] = v
+	}
+	for k, v := range r.answers {
+		cp.Answers[k] = v
+	}
+	r.humanMu.Unlock()
+	cp.SavedAt = time.Now()
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.statePath, data)
+}
+
+// clearState 在工作流结束后删除状态文件与答案文件。
+func (r *WorkflowRuntime) clearState() {
+	if r.statePath == "" {
+		return
+	}
+	_ = os.Remove(r.statePath)
+	_ = os.Remove(AnswersPath(r.statePath))
+}
+
+// LoadCheckpoint 读取状态文件。
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid state file '%s': %w", path, err)
+	}
+	return &cp, nil
+}
+
+// ResumeRuntime 根据状态文件恢复一个等待中的运行。
+func ResumeRuntime(statePath string, opts ...Option) (*WorkflowRuntime, error) {
+	cp, err := LoadCheckpoint(statePath)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := dsl.ParseWorkflow(cp.Workflow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workflow '%s': %w", cp.Workflow, err)
+	}
+
+	r := NewRuntime(wf, append(opts, WithStateFile(statePath))...)
+
+	r.memory = memory.NewMemory()
+	for k, v := range cp.Memory {
+		_ = r.memory.Set(k, v)
+	}
+	for _, id := range cp.ExecutedSteps {
+		r.executedSteps[id] = true
+	}
+	r.completed = cp.Completed
+	if cp.RunID != "" {
+		r.runID = cp.RunID
+	}
+	// 恢复的运行继续写入原来的运行目录
+	if cp.RunsDir != "" {
+		r.runsDir = cp.RunsDir
+	}
+	for id, n := range cp.Iterations {
+		r.iterations[id] = n
+	}
+	// The checkpointed superstep is executed again and numbered the same
+	if cp.Superstep > 0 {
+		r.superstep = cp.Superstep - 1
+	}
+	for _, cr := range cp.LastResults {
+		res := StepResult{
+			NodeName: cr.NodeName,
+			Ignored:  cr.Ignored,
+			Fallback: cr.Fallback,
+			Status:   cr.Status,
+			ErrorMsg: cr.Error,
+		}
+		if cr.Error != "" {
+			res.Err = errors.New(cr.Error)
+		}
+		r.lastResults = append(r.lastResults, res)
+	}
+	if cp.Trace != nil {
+		r.trace = cp.Trace
+	}
+	for k, v := range cp.Answers {
+		r.answers[k] = v
+	}
+	if answers, err := readAnswers(AnswersPath(statePath)); err == nil {
+		for k, v := range answers {
+			r.answers[k] = v
+		}
+	}
+	return r, nil
+}
+
+// StateFileName 是运行目录中状态文件的文件名。
+const StateFileName = "state.json"
+
+// AnswersPath 返回状态文件对应的答案文件路径。
+func AnswersPath(statePath string) string {
+	return statePath + ".answers"
+}
+
+// WriteAnswer 为状态文件中等待的人工步骤写入答案，供运行中或之后恢复的进程读取。
+func WriteAnswer(statePath, stepID, value string) error {
+	cp, err := LoadCheckpoint(statePath)
+	if err != nil {
+		return err
+	}
+	var req *HumanRequest
+	for i := range cp.Pending {
+		if cp.Pending[i].StepID == stepID {
+			req = &cp.Pending[i]
+		}
+	}
+	if req == nil {
+		return fmt.Errorf("step '%s' is not waiting for input", stepID)
+	}
+	answer, err := normalizeAnswer(*req, value)
+	if err != nil {
+		return err
+	}
+
+	path := AnswersPath(statePath)
+	answers, err := readAnswers(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		answers = make(map[string]string)
+	}
+	answers[stepID] = answer
+	data, err := json.MarshalIndent(answers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// removeAnswer 从答案文件中删除已使用的答案。
+func removeAnswer(path, stepID string) error {
+	answers, err := readAnswers(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := answers[stepID]; !ok {
+		return nil
+	}
+	delete(answers, stepID)
+	data, err := json.MarshalIndent(answers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func readAnswers(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	answers := make(map[string]string)
+	if err := json.Unmarshal(data, &answers); err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
+
+// normalizeAnswer 校验输入并转换为统一形式：approval 类型为 approved 或 rejected。
+func normalizeAnswer(req HumanRequest, value string) (string, error) {
+	switch req.Input {
+	case "approval":
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "approve", "approved", "yes", "y", "true":
+			return "approved", nil
+		case "reject", "rejected", "no", "n", "false":
+			return "rejected", nil
+		}
+		return "", fmt.Errorf("approval answer must be approve or reject, got '%s'", value)
+	case "choice":
+		for _, opt := range req.Options {
+			if opt == value {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("answer '%s' is not one of %v", value, req.Options)
+	default:
+		return value, nil
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,153 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"floe/dsl"
+)
+
+const approvalWorkflow = `workflow:
+  name: approval
+  steps:
+    - id: approve
+      type: human
+      human:
+        prompt: "Ship it?"
+      output: global.approval
+      next: done
+    - id: done
+      type: task
+      tool: log
+      input:
+        step: done
+`
+
+func parseApprovalWorkflow(t *testing.T) *dsl.Workflow {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "approval.yaml")
+	if err := os.WriteFile(path, []byte(approvalWorkflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wf, err := dsl.ParseWorkflow(path)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return wf
+}
+
+// waitForPending waits until the state file lists stepID as waiting for input.
+func waitForPending(t *testing.T, statePath, stepID string) *Checkpoint {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cp, err := LoadCheckpoint(statePath); err == nil {
+			for _, req := range cp.Pending {
+				if req.StepID == stepID {
+					return cp
+				}
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("step %s never waited for input in %s", stepID, statePath)
+	return nil
+}
+
+func waitForRun(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not finish after the answer")
+	}
+}
+
+func checkApproved(t *testing.T, r *WorkflowRuntime, log *callLog, statePath string) {
+	t.Helper()
+	if v, err := r.memory.Get("global.approval"); err != nil || v != "approved" {
+		t.Errorf("global.approval = %v (%v), want approved", v, err)
+	}
+	if len(log.steps) != 1 || log.steps[0] != "done" {
+		t.Errorf("steps after the answer = %v, want [done]", log.steps)
+	}
+	if len(r.answers) != 0 {
+		t.Errorf("answers left after the run: %v", r.answers)
+	}
+	for _, path := range []string{statePath, AnswersPath(statePath)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after the run", path)
+		}
+	}
+}
+
+func TestHumanStepPollsWrittenAnswer(t *testing.T) {
+	r := newTestRuntime(t, parseApprovalWorkflow(t))
+	log := &callLog{}
+	r.tools["log"] = log
+	statePath := filepath.Join(r.RunDir(), StateFileName)
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run() }()
+
+	cp := waitForPending(t, statePath, "approve")
+	if cp.Pending[0].Prompt != "Ship it?" || cp.Pending[0].Input != "approval" {
+		t.Errorf("pending request = %+v", cp.Pending[0])
+	}
+	if err := WriteAnswer(statePath, "done", "yes"); err == nil {
+		t.Error("answering a step that is not waiting succeeded")
+	}
+	if err := WriteAnswer(statePath, "approve", "maybe"); err == nil {
+		t.Error("an invalid approval answer was accepted")
+	}
+	if err := WriteAnswer(statePath, "approve", "yes"); err != nil {
+		t.Fatalf("answer: %v", err)
+	}
+
+	waitForRun(t, done)
+	checkApproved(t, r, log, statePath)
+}
+
+func TestResumeRuntimeUsesWrittenAnswer(t *testing.T) {
+	first := newTestRuntime(t, parseApprovalWorkflow(t))
+	first.tools["log"] = &callLog{}
+	done := make(chan error, 1)
+	go func() { done <- first.Run() }()
+
+	// Keep a copy of the state as a process that exits while waiting leaves it
+	waiting := waitForPending(t, filepath.Join(first.RunDir(), StateFileName), "approve")
+	data, err := os.ReadFile(filepath.Join(first.RunDir(), StateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(t.TempDir(), StateFileName)
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Answer("approve", "reject"); err != nil {
+		t.Fatalf("answer the first run: %v", err)
+	}
+	waitForRun(t, done)
+
+	if err := WriteAnswer(statePath, "approve", "approve"); err != nil {
+		t.Fatalf("answer: %v", err)
+	}
+	r, err := ResumeRuntime(statePath, testOptions(t)...)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if r.RunID() != waiting.RunID {
+		t.Errorf("resumed run ID = %s, want %s", r.RunID(), waiting.RunID)
+	}
+	log := &callLog{}
+	r.tools["log"] = log
+	resumed := make(chan error, 1)
+	go func() { resumed <- r.Run() }()
+	waitForRun(t, resumed)
+	checkApproved(t, r, log, statePath)
+}
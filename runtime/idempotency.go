@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+// newRunID 生成运行 ID：时间戳加随机后缀，按时间排序且不易冲突。
+func newRunID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// RunID 返回本次运行的 ID。恢复的运行沿用原来的 ID。
+func (r *WorkflowRuntime) RunID() string {
+	return r.runID
+}
+
+// nextIteration 返回步骤在本次运行中的第几次执行（从 1 开始）。
+func (r *WorkflowRuntime) nextIteration(stepID string) int {
+	r.iterMu.Lock()
+	defer r.iterMu.Unlock()
+	r.iterations[stepID]++
+	return r.iterations[stepID]
+}
+
+func (r *WorkflowRuntime) snapshotIterations() map[string]int {
+	r.iterMu.Lock()
+	defer r.iterMu.Unlock()
+	out := make(map[string]int, len(r.iterations))
+	for id, n := range r.iterations {
+		out[id] = n
+	}
+	return out
+}
+
+// idempotencyKey 返回步骤某次执行的幂等键。同一次执行的所有重试共用该键，
+// 恢复运行后重新执行的步骤也会得到相同的键。
+func (r *WorkflowRuntime) idempotencyKey(stepID string, iteration int) string {
+	return fmt.Sprintf("%s:%s:%d", r.runID, stepID, iteration)
+}
+
+// retryAllowed 判断步骤能否自动重试：工具声明为非幂等时，
+// 只有处理器显式设置 retry_non_idempotent 才允许重试。
+func (r *WorkflowRuntime) retryAllowed(step *dsl.Step, h dsl.ErrorConfig, input map[string]interface{}) bool {
+	if h.RetryNonIdempotent || step.Tool == "" || step.Type == "parallel" || step.Type == "human" {
+		return true
+	}
+	tool, err := r.tool(step.Tool)
+	if err != nil {
+		return true
+	}
+	return tools.IsIdempotent(tool, input)
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+// keyTool records the idempotency key of every call and fails the calls
+// listed in failOn (1-based). Calls are idempotent unless unsafe is set.
+type keyTool struct {
+	mu     sync.Mutex
+	keys   []string
+	failOn map[int]bool
+	unsafe bool
+}
+
+func (k *keyTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append(k.keys, tools.IdempotencyKey(ctx))
+	if k.failOn[len(k.keys)] {
+		return nil, errors.New("call failed")
+	}
+	return len(k.keys), nil
+}
+
+func (k *keyTool) Idempotent(input map[string]interface{}) bool { return !k.unsafe }
+
+func TestIdempotencyKeyIsStableAcrossRetries(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "keys",
+		Steps: []dsl.Step{{
+			ID: "call", Type: "task", Tool: "keys",
+			Error: dsl.ErrorConfig{Strategy: "retry", Retries: 2},
+		}},
+	}
+	r := newTestRuntime(t, wf)
+	tool := &keyTool{failOn: map[int]bool{1: true, 2: true}}
+	r.tools["keys"] = tool
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if len(tool.keys) != 3 {
+		t.Fatalf("got %d calls, want 3", len(tool.keys))
+	}
+	want := r.RunID() + ":call:1"
+	for i, key := range tool.keys {
+		if key != want {
+			t.Errorf("call %d key = %q, want %q", i+1, key, want)
+		}
+	}
+	if s := traceStep(r, "call"); s == nil || s.IdempotencyKey != want {
+		t.Errorf("trace key = %+v, want %q", s, want)
+	}
+}
+
+func TestIdempotencyKeyDiffersAcrossExecutionsAndRuns(t *testing.T) {
+	// a and b fail and both run shared in their place, so shared runs twice
+	fallback := dsl.ErrorConfig{Chain: []dsl.ErrorConfig{{Strategy: "fallback", Fallback: "shared"}}}
+	wf := &dsl.Workflow{
+		Name: "executions",
+		Steps: []dsl.Step{
+			{ID: "a", Type: "task", Tool: "keys", Error: fallback, Next: "b"},
+			{ID: "b", Type: "task", Tool: "keys", Error: fallback, Next: "end"},
+			{ID: "shared", Type: "task", Tool: "keys"},
+			{ID: "end", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "end"}},
+		},
+	}
+	var keys []string
+	for run := 0; run < 2; run++ {
+		r := newTestRuntime(t, wf)
+		tool := &keyTool{failOn: map[int]bool{1: true, 3: true}}
+		r.tools["keys"] = tool
+		r.tools["log"] = &callLog{}
+		if err := r.Run(); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		id := r.RunID()
+		want := []string{id + ":a:1", id + ":shared:1", id + ":b:1", id + ":shared:2"}
+		if !reflect.DeepEqual(tool.keys, want) {
+			t.Errorf("keys = %v, want %v", tool.keys, want)
+		}
+		keys = append(keys, tool.keys...)
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if seen[key] {
+			t.Errorf("key %q was used by more than one execution", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestNonIdempotentToolIsNotRetried(t *testing.T) {
+	tests := []struct {
+		name      string
+		allow     bool
+		wantCalls int
+	}{
+		{"not retried", false, 1},
+		{"retried when the step allows it", true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := &dsl.Workflow{
+				Name: "unsafe",
+				Steps: []dsl.Step{{
+					ID: "call", Type: "task", Tool: "keys",
+					Error: dsl.ErrorConfig{Strategy: "retry", Retries: 2, RetryNonIdempotent: tt.allow},
+				}},
+			}
+			r := newTestRuntime(t, wf)
+			tool := &keyTool{failOn: map[int]bool{1: true, 2: true, 3: true}, unsafe: true}
+			r.tools["keys"] = tool
+
+			err := r.Run()
+			if err == nil {
+				t.Fatal("run succeeded")
+			}
+			if len(tool.keys) != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", len(tool.keys), tt.wantCalls)
+			}
+			s := traceStep(r, "call")
+			if !tt.allow && (s == nil || s.Retries != 0 || !strings.Contains(s.Error, "not idempotent")) {
+				t.Errorf("step trace = %+v, want no retries and a not idempotent error", s)
+			}
+		})
+	}
+}
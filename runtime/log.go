@@ -0,0 +1,116 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"floe/internal/runtime_integration"
+)
+
+// WithLogger 设置运行时的日志记录器。每条日志会附带运行 ID 与当前 Superstep，
+// 并同时以 log 事件发布，供 TUI 等订阅者展示。
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *WorkflowRuntime) {
+		r.setLogger(logger)
+	}
+}
+
+// defaultLogger 以文本格式将 info 及以上级别的日志写到标准错误。
+func defaultLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+// Logger 返回运行时的日志记录器，写入的日志同样以 log 事件发布。
+func (r *WorkflowRuntime) Logger() *slog.Logger {
+	return r.log
+}
+
+func (r *WorkflowRuntime) setLogger(logger *slog.Logger) {
+	r.baseLog = logger
+	r.log = slog.New(&eventHandler{next: logger.Handler(), r: r})
+}
+
+// eventHandler 为日志补充运行上下文，并将每条日志转换为 log 事件。
+type eventHandler struct {
+	next  slog.Handler
+	r     *WorkflowRuntime
+	attrs []slog.Attr
+}
+
+func (h *eventHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *eventHandler) Handle(ctx context.Context, rec slog.Record) error {
+	rec = rec.Clone()
+	rec.AddAttrs(slog.String("run_id", h.r.runID))
+	if h.r.superstep > 0 {
+		rec.AddAttrs(slog.Int("superstep", h.r.superstep))
+	}
+
+	payload := map[string]interface{}{
+		"level":   rec.Level.String(),
+		"message": rec.Message,
+	}
+	for _, a := range h.attrs {
+		payload[a.Key] = a.Value.Resolve().Any()
+	}
+	rec.Attrs(func(a slog.Attr) bool {
+		v := a.Value.Resolve().Any()
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		payload[a.Key] = v
+		return true
+	})
+	h.r.Emit(runtime_integration.Event{Type: runtime_integration.EventLog, Timestamp: rec.Time, Payload: payload})
+
+	return h.next.Handle(ctx, rec)
+}
+
+func (h *eventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventHandler{
+		next:  h.next.WithAttrs(attrs),
+		r:     h.r,
+		attrs: append(append([]slog.Attr(nil), h.attrs...), attrs...),
+	}
+}
+
+// WithGroup 只影响下游 Handler 的输出；事件中的属性不分组。
+func (h *eventHandler) WithGroup(name string) slog.Handler {
+	return &eventHandler{next: h.next.WithGroup(name), r: h.r, attrs: h.attrs}
+}
+
+// logWriter 把写入的内容按行记录为 warn 日志，用于转发子进程的标准错误输出。
+type logWriter struct {
+	log *slog.Logger
+	msg string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func newLogWriter(log *slog.Logger, msg string) *logWriter {
+	return &logWriter{log: log, msg: msg}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimRight(string(w.buf[:i]), "\r"); line != "" {
+			w.log.Warn(w.msg, "line", line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,113 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"floe/dsl"
+	"floe/internal/runtime_integration"
+)
+
+// newLoggedRuntime returns a runtime logging JSON into buf at level, and a
+// subscription to its log events.
+func newLoggedRuntime(t *testing.T, buf *bytes.Buffer, level slog.Level) (*WorkflowRuntime, *runtime_integration.Subscription) {
+	t.Helper()
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: level}))
+	r := newTestRuntime(t, &dsl.Workflow{Name: "logs"}, WithLogger(logger))
+	sub := r.Subscribe(runtime_integration.SubscribeOptions{Types: []runtime_integration.EventType{runtime_integration.EventLog}})
+	return r, sub
+}
+
+func logEvents(sub *runtime_integration.Subscription) []map[string]interface{} {
+	sub.Unsubscribe()
+	var payloads []map[string]interface{}
+	for e := range sub.Events() {
+		payloads = append(payloads, e.Payload)
+	}
+	return payloads
+}
+
+func TestLogRecordsAreForwardedAsEvents(t *testing.T) {
+	var buf bytes.Buffer
+	r, sub := newLoggedRuntime(t, &buf, slog.LevelInfo)
+	r.superstep = 2
+	r.Logger().Debug("hidden")
+	r.Logger().Warn("tool failed", "step_id", "fetch", "attempts", 3, "error", errors.New("boom"))
+
+	events := logEvents(sub)
+	if len(events) != 1 {
+		t.Fatalf("got %d log events, want 1 (debug is below the level): %v", len(events), events)
+	}
+	want := map[string]interface{}{
+		"level":     "WARN",
+		"message":   "tool failed",
+		"step_id":   "fetch",
+		"attempts":  int64(3),
+		"error":     "boom",
+		"run_id":    r.RunID(),
+		"superstep": int64(2),
+	}
+	for k, v := range want {
+		if events[0][k] != v {
+			t.Errorf("payload[%s] = %#v, want %#v", k, events[0][k], v)
+		}
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("downstream output %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "tool failed" || line["run_id"] != r.RunID() || line["error"] != "boom" {
+		t.Errorf("downstream record = %v", line)
+	}
+}
+
+func TestLoggerWithAttrsAndGroup(t *testing.T) {
+	var buf bytes.Buffer
+	r, sub := newLoggedRuntime(t, &buf, slog.LevelInfo)
+	r.Logger().With("server", "files").WithGroup("mcp").Info("started", "pid", 42)
+
+	events := logEvents(sub)
+	if len(events) != 1 {
+		t.Fatalf("got %d log events, want 1", len(events))
+	}
+	// Event attributes are not grouped
+	if events[0]["server"] != "files" || events[0]["pid"] != int64(42) {
+		t.Errorf("payload = %v, want server and pid", events[0])
+	}
+
+	var line struct {
+		Server string `json:"server"`
+		MCP    struct {
+			PID int `json:"pid"`
+		} `json:"mcp"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("downstream output %q: %v", buf.String(), err)
+	}
+	if line.Server != "files" || line.MCP.PID != 42 {
+		t.Errorf("downstream record = %s, want server at the top and pid in the mcp group", buf.String())
+	}
+}
+
+func TestLogWriterLogsCompleteLines(t *testing.T) {
+	var buf bytes.Buffer
+	r, sub := newLoggedRuntime(t, &buf, slog.LevelInfo)
+	w := newLogWriter(r.Logger(), "stderr")
+	w.Write([]byte("first\r\n\nsec"))
+	w.Write([]byte("ond\npartial"))
+
+	var lines []interface{}
+	for _, p := range logEvents(sub) {
+		if p["message"] != "stderr" || p["level"] != "WARN" {
+			t.Errorf("payload = %v", p)
+		}
+		lines = append(lines, p["line"])
+	}
+	if len(lines) != 2 || lines[0] != "first" || lines[1] != "second" {
+		t.Errorf("logged lines = %v, want [first second]", lines)
+	}
+}
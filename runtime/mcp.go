@@ -0,0 +1,48 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	"floe/tools"
+)
+
+// mcpStartTimeout bounds the handshake and tool listing of each MCP server.
+const mcpStartTimeout = 30 * time.Second
+
+// startMCPServers 启动工作流声明的 MCP Server，并将其工具注册到本次运行的工具表。
+// Server 的标准错误输出写入运行日志。任意一个 Server 启动失败时，已启动的 Server 会被关闭。
+func (r *WorkflowRuntime) startMCPServers() ([]*tools.MCPClient, error) {
+	var clients []*tools.MCPClient
+	for _, cfg := range r.workflow.MCPServers {
+		ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+		client, err := tools.StartMCPServer(ctx, tools.MCPServerConfig{
+			Name:    cfg.Name,
+			Command: cfg.Command,
+			Args:    cfg.Args,
+			Env:     cfg.Env,
+			Dir:     cfg.Dir,
+			Stderr:  newLogWriter(r.log.With("mcp_server", cfg.Name), "mcp server stderr"),
+		})
+		var serverTools map[string]tools.Tool
+		if err == nil {
+			clients = append(clients, client)
+			serverTools, err = client.Tools(ctx)
+		}
+		cancel()
+		if err != nil {
+			closeMCPServers(clients)
+			return nil, err
+		}
+		for name, tool := range serverTools {
+			r.tools[name] = tool
+		}
+	}
+	return clients, nil
+}
+
+func closeMCPServers(clients []*tools.MCPClient) {
+	for _, c := range clients {
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,77 @@
+package runtime
+
+import (
+	"strings"
+
+	"floe/internal/metrics"
+)
+
+// WithMetrics 指定记录运行指标的注册表，默认为 metrics.Default。
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(r *WorkflowRuntime) {
+		r.metrics = newRuntimeMetrics(reg)
+	}
+}
+
+// runtimeMetrics 是运行时记录的 Prometheus 指标。
+type runtimeMetrics struct {
+	workflowsStarted   *metrics.Counter
+	workflowsCompleted *metrics.Counter
+	workflowsFailed    *metrics.Counter
+	activeRuns         *metrics.Gauge
+	stepDuration       *metrics.Histogram
+	stepRetries        *metrics.Counter
+	stepFallbacks      *metrics.Counter
+	stepsSkipped       *metrics.Counter
+	eventsDropped      *metrics.Counter
+}
+
+// newRuntimeMetrics 在 reg 中注册运行时指标。同一注册表可被多个运行时共享。
+func newRuntimeMetrics(reg *metrics.Registry) *runtimeMetrics {
+	return &runtimeMetrics{
+		workflowsStarted:   reg.Counter("floe_workflows_started_total", "Workflow runs started.", "workflow"),
+		workflowsCompleted: reg.Counter("floe_workflows_completed_total", "Workflow runs that completed successfully.", "workflow"),
+		workflowsFailed:    reg.Counter("floe_workflows_failed_total", "Workflow runs that failed or timed out.", "workflow", "reason"),
+		activeRuns:         reg.Gauge("floe_active_runs", "Workflow runs currently executing.", "workflow"),
+		stepDuration:       reg.Histogram("floe_step_duration_seconds", "Step execution time including retries.", nil, "tool", "status"),
+		stepRetries:        reg.Counter("floe_step_retries_total", "Step retries.", "tool"),
+		stepFallbacks:      reg.Counter("floe_step_fallbacks_total", "Fallbacks triggered by failing steps.", "tool"),
+		stepsSkipped:       reg.Counter("floe_steps_skipped_total", "Steps skipped because their when condition was false.", "workflow"),
+		eventsDropped:      reg.Counter("floe_events_dropped_total", "Events dropped because a subscriber's buffer was full.", "workflow"),
+	}
+}
+
+func (m *runtimeMetrics) runStarted(workflow string) {
+	m.workflowsStarted.Inc(workflow)
+	m.activeRuns.Add(1, workflow)
+}
+
+func (m *runtimeMetrics) runEnded(workflow, status string, dropped uint64) {
+	m.activeRuns.Add(-1, workflow)
+	if status == "success" {
+		m.workflowsCompleted.Inc(workflow)
+	} else {
+		m.workflowsFailed.Inc(workflow, status)
+	}
+	m.eventsDropped.Add(float64(dropped), workflow)
+}
+
+// stepEnded 记录一次步骤执行的耗时、重试与 Fallback。
+func (m *runtimeMetrics) stepEnded(tool string, res StepResult) {
+	status := "success"
+	switch {
+	case res.Status == "timed_out":
+		status = "timed_out"
+	case res.Err != nil || res.ErrorMsg != "":
+		status = "failed"
+	}
+	m.stepDuration.Observe(res.EndedAt.Sub(res.StartedAt).Seconds(), tool, status)
+	if res.Retries > 0 {
+		m.stepRetries.Add(float64(res.Retries), tool)
+	}
+	for _, h := range res.Handlers {
+		if strings.HasPrefix(h, "fallback:") {
+			m.stepFallbacks.Inc(tool)
+		}
+	}
+}
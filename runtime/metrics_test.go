@@ -0,0 +1,53 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"floe/dsl"
+	"floe/internal/metrics"
+	"floe/tools"
+)
+
+func TestRunRecordsMetricsInItsRegistry(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "metered",
+		Steps: []dsl.Step{
+			{ID: "call", Type: "task", Tool: "seq", Error: dsl.ErrorConfig{Strategy: "retry", Retries: 1}, Next: "skip"},
+			{ID: "skip", Type: "task", Tool: "seq", When: "1 == 2"},
+		},
+	}
+	reg := metrics.NewRegistry()
+	r := newTestRuntime(t, wf, WithMetrics(reg))
+	r.tools["seq"] = &sequenceTool{errs: []error{tools.Retryable(errors.New("busy"))}}
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`floe_workflows_started_total{workflow="metered"} 1`,
+		`floe_workflows_completed_total{workflow="metered"} 1`,
+		`floe_active_runs{workflow="metered"} 0`,
+		`floe_step_retries_total{tool="seq"} 1`,
+		`floe_step_duration_seconds_count{tool="seq",status="success"} 1`,
+		`floe_steps_skipped_total{workflow="metered"} 1`,
+		`floe_events_dropped_total{workflow="metered"} 0`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("metrics are missing %s:\n%s", want, got)
+		}
+	}
+
+	var def bytes.Buffer
+	_ = metrics.Default.WriteText(&def)
+	if strings.Contains(def.String(), `workflow="metered"`) {
+		t.Error("the run also recorded into the default registry")
+	}
+}
@@ -0,0 +1,356 @@
+package runtime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// OTLP 中的 Span 类型与状态码。
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusOK    = 1
+	spanStatusError = 2
+)
+
+// WithOTLPFile 在运行结束后将 trace 以 OTLP/JSON 格式写入 path。
+func WithOTLPFile(path string) Option {
+	return func(r *WorkflowRuntime) {
+		r.otlpFile = path
+	}
+}
+
+// WithOTLPEndpoint 在运行结束后将 trace 以 OTLP/JSON 格式发送到 OTLP HTTP 接收端。
+// endpoint 没有路径时使用标准路径 /v1/traces。
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(r *WorkflowRuntime) {
+		r.otlpEndpoint = endpoint
+	}
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // OTLP/JSON 中 int64 以字符串表示
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func strAttr(key, v string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &v}}
+}
+
+func intAttr(key string, v int64) otlpKeyValue {
+	s := strconv.FormatInt(v, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func boolAttr(key string, v bool) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{BoolValue: &v}}
+}
+
+func floatAttr(key string, v float64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{DoubleValue: &v}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// spanID 由运行 ID 与 Span 在树中的路径派生，重复导出同一 trace 得到相同的 ID。
+func spanID(runID, path string) string {
+	sum := sha256.Sum256([]byte(runID + "/" + path))
+	return hex.EncodeToString(sum[:8])
+}
+
+// OTLP 将 trace 转换为 OTLP/JSON 格式的 Span 树：
+// workflow → superstep → step → attempt → tool。
+func (t *Trace) OTLP() ([]byte, error) {
+	sum := sha256.Sum256([]byte(t.RunID))
+	traceID := hex.EncodeToString(sum[:16])
+
+	var spans []otlpSpan
+	add := func(span otlpSpan) {
+		span.TraceID = traceID
+		if span.Kind == 0 {
+			span.Kind = spanKindInternal
+		}
+		spans = append(spans, span)
+	}
+
+	rootID := spanID(t.RunID, "workflow")
+	root := otlpSpan{
+		SpanID:            rootID,
+		Name:              "workflow " + t.Workflow,
+		StartTimeUnixNano: unixNano(t.StartedAt),
+		EndTimeUnixNano:   unixNano(t.EndedAt),
+		Attributes: []otlpKeyValue{
+			strAttr("floe.workflow", t.Workflow),
+			strAttr("floe.run_id", t.RunID),
+			strAttr("floe.status", t.Status),
+		},
+		Status: otlpStatus{Code: spanStatusOK},
+	}
+	if t.Status != "success" {
+		root.Status = otlpStatus{Code: spanStatusError, Message: t.Error}
+	}
+	add(root)
+
+	for i := 0; i < len(t.Steps); {
+		// 同一 Superstep 的步骤在 trace 中是连续的
+		j := i
+		for j < len(t.Steps) && t.Steps[j].Superstep == t.Steps[i].Superstep {
+			j++
+		}
+		group := t.Steps[i:j]
+
+		n := group[0].Superstep
+		superstepID := spanID(t.RunID, fmt.Sprintf("superstep/%d/%d", n, i))
+		start, end := group[0].StartedAt, group[0].EndedAt
+		failed := false
+		for _, step := range group {
+			if step.StartedAt.Before(start) {
+				start = step.StartedAt
+			}
+			if step.EndedAt.After(end) {
+				end = step.EndedAt
+			}
+			if step.Error != "" && !step.Ignored {
+				failed = true
+			}
+		}
+		superstep := otlpSpan{
+			SpanID:            superstepID,
+			ParentSpanID:      rootID,
+			Name:              fmt.Sprintf("superstep %d", n),
+			StartTimeUnixNano: unixNano(start),
+			EndTimeUnixNano:   unixNano(end),
+			Attributes: []otlpKeyValue{
+				intAttr("floe.superstep", int64(n)),
+				intAttr("floe.superstep.steps", int64(len(group))),
+			},
+			Status: otlpStatus{Code: spanStatusOK},
+		}
+		if failed {
+			superstep.Status = otlpStatus{Code: spanStatusError}
+		}
+		add(superstep)
+
+		for k, step := range group {
+			path := fmt.Sprintf("step/%d", i+k)
+			for _, span := range stepSpans(t.RunID, path, superstepID, step) {
+				add(span)
+			}
+		}
+		i = j
+	}
+
+	return json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			strAttr("service.name", "floe"),
+			strAttr("floe.workflow", t.Workflow),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "floe/runtime"},
+			Spans: spans,
+		}},
+	}}})
+}
+
+// stepSpans 返回一个步骤及其各次尝试、工具调用的 Span。
+func stepSpans(runID, path, parentID string, step TraceEvent) []otlpSpan {
+	stepID := spanID(runID, path)
+	attrs := []otlpKeyValue{
+		strAttr("floe.step.id", step.StepName),
+		strAttr("floe.step.status", step.Status),
+		intAttr("floe.step.retries", int64(step.Retries)),
+		intAttr("floe.step.attempts", int64(len(step.Attempts))),
+	}
+	if step.Tool != "" {
+		attrs = append(attrs, strAttr("floe.tool", step.Tool))
+	}
+	if step.CacheHit {
+		attrs = append(attrs, boolAttr("floe.cache_hit", true))
+	}
+	if step.IdempotencyKey != "" {
+		attrs = append(attrs, strAttr("floe.idempotency_key", step.IdempotencyKey))
+	}
+	if step.Compensates != "" {
+		attrs = append(attrs, strAttr("floe.compensates", step.Compensates))
+	}
+	if u := step.Usage; u != nil {
+		attrs = append(attrs,
+			intAttr("floe.usage.input_tokens", u.InputTokens),
+			intAttr("floe.usage.output_tokens", u.OutputTokens),
+			floatAttr("floe.usage.cost", u.Cost),
+		)
+		if u.Model != "" {
+			attrs = append(attrs, strAttr("floe.usage.model", u.Model))
+		}
+	}
+	if step.Error != "" {
+		attrs = append(attrs, strAttr("floe.error", step.Error), strAttr("floe.error.kind", step.ErrorKind))
+		if step.Ignored {
+			attrs = append(attrs, boolAttr("floe.error.ignored", true))
+		}
+	}
+
+	span := otlpSpan{
+		SpanID:            stepID,
+		ParentSpanID:      parentID,
+		Name:              "step " + step.StepName,
+		StartTimeUnixNano: unixNano(step.StartedAt),
+		EndTimeUnixNano:   unixNano(step.EndedAt),
+		Attributes:        attrs,
+		Status:            otlpStatus{Code: spanStatusOK},
+	}
+	if step.Error != "" && !step.Ignored {
+		span.Status = otlpStatus{Code: spanStatusError, Message: step.Error}
+	}
+	spans := []otlpSpan{span}
+
+	for _, a := range step.Attempts {
+		attemptID := spanID(runID, fmt.Sprintf("%s/attempt/%d", path, a.Attempt))
+		start := a.StartedAt
+		end := start.Add(time.Duration(a.DurationMs) * time.Millisecond)
+		status := otlpStatus{Code: spanStatusOK}
+		if a.Error != "" {
+			status = otlpStatus{Code: spanStatusError, Message: a.Error}
+		}
+
+		attemptAttrs := []otlpKeyValue{intAttr("floe.attempt", int64(a.Attempt))}
+		if a.Error != "" {
+			attemptAttrs = append(attemptAttrs, strAttr("floe.error", a.Error), strAttr("floe.error.kind", a.ErrorKind))
+		}
+		if a.DelayMs > 0 {
+			attemptAttrs = append(attemptAttrs, intAttr("floe.retry.delay_ms", a.DelayMs))
+		}
+		if a.CacheHit {
+			attemptAttrs = append(attemptAttrs, boolAttr("floe.cache_hit", true))
+		}
+		spans = append(spans, otlpSpan{
+			SpanID:            attemptID,
+			ParentSpanID:      stepID,
+			Name:              fmt.Sprintf("attempt %d", a.Attempt),
+			StartTimeUnixNano: unixNano(start),
+			EndTimeUnixNano:   unixNano(end),
+			Attributes:        attemptAttrs,
+			Status:            status,
+		})
+
+		// 缓存命中或调用前失败时没有真正调用工具
+		if step.Tool == "" || a.Tool == nil {
+			continue
+		}
+		spans = append(spans, otlpSpan{
+			SpanID:            spanID(runID, fmt.Sprintf("%s/attempt/%d/tool", path, a.Attempt)),
+			ParentSpanID:      attemptID,
+			Name:              "tool " + step.Tool,
+			Kind:              spanKindClient,
+			StartTimeUnixNano: unixNano(a.Tool.StartedAt),
+			EndTimeUnixNano:   unixNano(a.Tool.EndedAt),
+			Attributes:        []otlpKeyValue{strAttr("floe.tool", step.Tool)},
+			Status:            status,
+		})
+	}
+	return spans
+}
+
+// exportSpans 将本次运行的 trace 导出到配置的 OTLP 文件或接收端。
+func (r *WorkflowRuntime) exportSpans() error {
+	if r.otlpFile == "" && r.otlpEndpoint == "" {
+		return nil
+	}
+	data, err := r.trace.OTLP()
+	if err != nil {
+		return err
+	}
+	if r.otlpFile != "" {
+		if dir := filepath.Dir(r.otlpFile); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(r.otlpFile, data, 0644); err != nil {
+			return err
+		}
+	}
+	if r.otlpEndpoint != "" {
+		return postOTLP(r.otlpEndpoint, data)
+	}
+	return nil
+}
+
+func postOTLP(endpoint string, data []byte) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(u.String(), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to send spans to %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP endpoint %s returned %s", u, resp.Status)
+	}
+	return nil
+}
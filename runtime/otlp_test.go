@@ -0,0 +1,158 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"floe/dsl"
+)
+
+// flakyTool fails its first call and sleeps before answering, so the tool
+// span is visibly shorter than its attempt.
+type flakyTool struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (t *flakyTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	t.mu.Lock()
+	t.calls++
+	n := t.calls
+	t.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	if n == 1 {
+		return nil, errors.New("first call fails")
+	}
+	return "ok", nil
+}
+
+func TestOTLPExportSpanTree(t *testing.T) {
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", req.URL.Path, req.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(req.Body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	wf := &dsl.Workflow{
+		Name: "otlp",
+		Steps: []dsl.Step{{
+			ID:    "call",
+			Type:  "task",
+			Tool:  "flaky",
+			Error: dsl.ErrorConfig{Strategy: "retry", Retries: 1},
+		}},
+	}
+	r := newTestRuntime(t, wf, WithOTLPEndpoint(srv.URL))
+	r.tools["flaky"] = &flakyTool{}
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var body []byte
+	select {
+	case body = <-received:
+	default:
+		t.Fatal("no spans were sent")
+	}
+	var traces otlpTraces
+	if err := json.Unmarshal(body, &traces); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+
+	byName := make(map[string][]otlpSpan)
+	byID := make(map[string]otlpSpan)
+	for _, s := range spans {
+		byName[s.Name] = append(byName[s.Name], s)
+		byID[s.SpanID] = s
+		if s.TraceID != spans[0].TraceID {
+			t.Errorf("span %s has trace ID %s, want %s", s.Name, s.TraceID, spans[0].TraceID)
+		}
+	}
+	parentName := func(s otlpSpan) string { return byID[s.ParentSpanID].Name }
+
+	root := byName["workflow otlp"]
+	if len(root) != 1 || root[0].ParentSpanID != "" {
+		t.Fatalf("workflow span = %+v", root)
+	}
+	if got := attr(root[0], "floe.run_id"); got != r.runID {
+		t.Errorf("floe.run_id = %q, want %q", got, r.runID)
+	}
+	if s := byName["superstep 1"]; len(s) != 1 || parentName(s[0]) != "workflow otlp" {
+		t.Errorf("superstep span = %+v", s)
+	}
+	step := byName["step call"]
+	if len(step) != 1 || parentName(step[0]) != "superstep 1" {
+		t.Fatalf("step span = %+v", step)
+	}
+	if attr(step[0], "floe.step.id") != "call" || attr(step[0], "floe.tool") != "flaky" || attr(step[0], "floe.step.attempts") != "2" {
+		t.Errorf("step attributes = %+v", step[0].Attributes)
+	}
+
+	for _, name := range []string{"attempt 1", "attempt 2"} {
+		a := byName[name]
+		if len(a) != 1 || parentName(a[0]) != "step call" {
+			t.Fatalf("%s span = %+v", name, a)
+		}
+	}
+	if byName["attempt 1"][0].Status.Code != spanStatusError || attr(byName["attempt 1"][0], "floe.error") != "first call fails" {
+		t.Errorf("attempt 1 = %+v", byName["attempt 1"][0])
+	}
+
+	toolSpans := byName["tool flaky"]
+	if len(toolSpans) != 2 {
+		t.Fatalf("got %d tool spans, want 2", len(toolSpans))
+	}
+	for _, tool := range toolSpans {
+		parent := byID[tool.ParentSpanID]
+		if parentName(parent) != "step call" || tool.Kind != spanKindClient {
+			t.Errorf("tool span %+v has parent %q", tool, parent.Name)
+		}
+		start, end := nanos(t, tool.StartTimeUnixNano), nanos(t, tool.EndTimeUnixNano)
+		if end-start < int64(10*time.Millisecond) {
+			t.Errorf("tool span lasts %v, want at least the tool's 10ms", time.Duration(end-start))
+		}
+		if start < nanos(t, parent.StartTimeUnixNano) {
+			t.Errorf("tool span starts before its attempt")
+		}
+		if tool.StartTimeUnixNano == parent.StartTimeUnixNano && tool.EndTimeUnixNano == parent.EndTimeUnixNano {
+			t.Errorf("tool span copies the attempt's times")
+		}
+	}
+}
+
+func attr(s otlpSpan, key string) string {
+	for _, kv := range s.Attributes {
+		if kv.Key != key {
+			continue
+		}
+		switch v := kv.Value; {
+		case v.StringValue != nil:
+			return *v.StringValue
+		case v.IntValue != nil:
+			return *v.IntValue
+		}
+	}
+	return ""
+}
+
+func nanos(t *testing.T, s string) int64 {
+	t.Helper()
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp %q: %v", s, err)
+	}
+	return n
+}
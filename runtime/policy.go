@@ -0,0 +1,337 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"floe/dsl"
+	"floe/internal/runtime_integration"
+	"floe/tools"
+)
+
+// 熔断器状态。
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// ErrCircuitOpen 表示调用因熔断器打开而被直接拒绝。
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError 是熔断器拒绝调用时返回的错误。
+type CircuitOpenError struct {
+	Policy     string        // 策略名
+	RetryAfter time.Duration // 距离进入半开状态的时间
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker '%s' is open, retry after %s", e.Policy, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// toolPolicy 是某条策略的运行时状态，在同一工作流的所有步骤间共享。
+type toolPolicy struct {
+	name    string
+	config  dsl.PolicyConfig
+	bucket  *tokenBucket
+	slots   chan struct{}
+	breaker *circuitBreaker
+}
+
+// policySet 管理工作流中所有工具调用策略。
+type policySet struct {
+	policies []*toolPolicy
+}
+
+func newPolicySet(configs []dsl.PolicyConfig, onStateChange func(policy, from, to string)) *policySet {
+	set := &policySet{}
+	for _, cfg := range configs {
+		p := &toolPolicy{name: cfg.Name, config: cfg}
+		if p.name == "" {
+			p.name = cfg.Tool
+			if p.name == "" {
+				p.name = cfg.Host
+			}
+		}
+		if cfg.RateLimit.Rate > 0 {
+			p.bucket = newTokenBucket(cfg.RateLimit.Rate, cfg.RateLimit.Burst)
+		}
+		if cfg.MaxConcurrent > 0 {
+			p.slots = make(chan struct{}, cfg.MaxConcurrent)
+		}
+		if cfg.CircuitBreaker.FailureThreshold > 0 {
+			name := p.name
+			p.breaker = newCircuitBreaker(cfg.CircuitBreaker, func(from, to string) {
+				onStateChange(name, from, to)
+			})
+		}
+		set.policies = append(set.policies, p)
+	}
+	return set
+}
+
+// matches 判断策略是否适用于某次工具调用。
+func (p *toolPolicy) matches(tool string, input map[string]interface{}) bool {
+	if p.config.Tool == "" && p.config.Host == "" {
+		return false
+	}
+	if p.config.Tool != "" {
+		if ok, _ := path.Match(p.config.Tool, tool); !ok {
+			return false
+		}
+	}
+	if p.config.Host != "" {
+		raw, _ := input["url"].(string)
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			return false
+		}
+		if ok, _ := path.Match(p.config.Host, u.Hostname()); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// acquire 依次通过所有匹配策略的熔断器、并发限制和限流。
+// 成功时返回的 release 必须在调用结束后以调用结果执行。中途失败时，
+// 已占用的探测名额和并发槽位会被归还，但不会向熔断器报告调用结果。
+func (s *policySet) acquire(ctx context.Context, tool string, input map[string]interface{}) (func(error), error) {
+	var held []policyHold
+	undo := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].undo()
+		}
+	}
+
+	for _, p := range s.policies {
+		if !p.matches(tool, input) {
+			continue
+		}
+		if p.breaker != nil {
+			done, cancel, err := p.breaker.allow(p.name)
+			if err != nil {
+				undo()
+				return nil, err
+			}
+			held = append(held, policyHold{done: done, undo: cancel})
+		}
+		if p.slots != nil {
+			select {
+			case p.slots <- struct{}{}:
+			case <-ctx.Done():
+				undo()
+				return nil, ctx.Err()
+			}
+			slots := p.slots
+			free := func() { <-slots }
+			held = append(held, policyHold{done: func(error) { free() }, undo: free})
+		}
+		if p.bucket != nil {
+			if err := p.bucket.wait(ctx); err != nil {
+				undo()
+				return nil, err
+			}
+		}
+	}
+	return func(err error) {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].done(err)
+		}
+	}, nil
+}
+
+// policyHold 是一条策略为某次调用占用的资源。done 在调用结束后报告结果，
+// undo 在调用未发生时归还资源。
+type policyHold struct {
+	done func(error)
+	undo func()
+}
+
+// tokenBucket 是令牌桶限流器，令牌不足时等待。
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// circuitBreaker 在连续失败达到阈值后拒绝调用，超时后进入半开状态探测。
+type circuitBreaker struct {
+	mu           sync.Mutex
+	threshold    int
+	resetTimeout time.Duration
+	halfOpenMax  int
+	onChange     func(from, to string)
+
+	state    string
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+func newCircuitBreaker(cfg dsl.CircuitBreakerConfig, onChange func(from, to string)) *circuitBreaker {
+	reset := time.Duration(cfg.ResetTimeoutMs) * time.Millisecond
+	if reset <= 0 {
+		reset = 30 * time.Second
+	}
+	halfOpenMax := cfg.HalfOpenMax
+	if halfOpenMax <= 0 {
+		halfOpenMax = 1
+	}
+	return &circuitBreaker{
+		threshold:    cfg.FailureThreshold,
+		resetTimeout: reset,
+		halfOpenMax:  halfOpenMax,
+		onChange:     onChange,
+		state:        BreakerClosed,
+	}
+}
+
+// allow 判断是否放行调用。放行时返回的 done 用于报告调用结果；
+// 调用最终没有发生时改用 cancel 归还探测名额，不影响熔断器状态。
+func (b *circuitBreaker) allow(policy string) (done func(error), cancel func(), err error) {
+	notify := func() {}
+	defer func() { notify() }() // 在释放锁之后通知，慢订阅者不会阻塞其他调用
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		wait := b.resetTimeout - time.Since(b.openedAt)
+		if wait > 0 {
+			return nil, nil, &CircuitOpenError{Policy: policy, RetryAfter: wait}
+		}
+		notify = b.setState(BreakerHalfOpen)
+	}
+
+	halfOpen := b.state == BreakerHalfOpen
+	if halfOpen {
+		if b.probes >= b.halfOpenMax {
+			// 探测结果未知；探测失败时熔断器会再打开一个重置周期
+			return nil, nil, &CircuitOpenError{Policy: policy, RetryAfter: b.resetTimeout}
+		}
+		b.probes++
+	}
+
+	done = func(err error) {
+		b.record(err, halfOpen)
+	}
+	cancel = func() {
+		if halfOpen {
+			b.returnProbe()
+		}
+	}
+	return done, cancel, nil
+}
+
+// returnProbe 归还未使用的探测名额。
+func (b *circuitBreaker) returnProbe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *circuitBreaker) record(err error, probe bool) {
+	notify := func() {}
+	defer func() { notify() }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe && b.probes > 0 {
+		b.probes--
+	}
+	if !countsAsFailure(err) {
+		b.failures = 0
+		if b.state == BreakerHalfOpen {
+			notify = b.setState(BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		notify = b.setState(BreakerOpen)
+	}
+}
+
+// setState 切换状态，返回通知状态变化的函数，由调用方在释放锁之后调用。
+// 调用方需持有锁。
+func (b *circuitBreaker) setState(state string) func() {
+	if b.state == state {
+		return func() {}
+	}
+	from := b.state
+	b.state = state
+	if state == BreakerHalfOpen {
+		b.probes = 0
+	}
+	if b.onChange == nil {
+		return func() {}
+	}
+	return func() { b.onChange(from, state) }
+}
+
+// countsAsFailure 判断调用结果是否计入熔断失败次数。
+// 输入错误和主动取消说明的是调用方的问题，不代表下游故障。
+func countsAsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !errors.Is(err, tools.ErrInvalidInput) && !errors.Is(err, context.Canceled)
+}
+
+// emitBreakerChange 以事件形式发布熔断器状态变化。
+func (r *WorkflowRuntime) emitBreakerChange(policy, from, to string) {
+	r.log.Warn("circuit breaker state changed", "policy", policy, "from", from, "to", to)
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventCircuitBreaker, map[string]interface{}{
+		"policy": policy,
+		"from":   from,
+		"to":     to,
+	}))
+}
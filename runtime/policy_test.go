@@ -0,0 +1,259 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"floe/dsl"
+	"floe/tools"
+)
+
+func TestCircuitBreakerNotifiesOutsideLock(t *testing.T) {
+	changed := make(chan string, 4)
+	release := make(chan struct{})
+	b := newCircuitBreaker(dsl.CircuitBreakerConfig{FailureThreshold: 1, ResetTimeoutMs: 60000}, func(from, to string) {
+		changed <- from + "->" + to
+		<-release // A slow subscriber
+	})
+
+	done, _, err := b.allow("p")
+	if err != nil {
+		t.Fatalf("allow: %v", err)
+	}
+	go done(errors.New("boom"))
+
+	select {
+	case got := <-changed:
+		if got != "closed->open" {
+			t.Errorf("transition = %s, want closed->open", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no state change reported")
+	}
+
+	// The notification is still blocked; other calls must not wait for it.
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := b.allow("p")
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		var open *CircuitOpenError
+		if !errors.As(err, &open) {
+			t.Errorf("allow on an open breaker = %v, want CircuitOpenError", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("allow blocked behind the state change notification")
+	}
+	close(release)
+}
+
+// breakerState reads the breaker state under its lock.
+func breakerState(b *circuitBreaker) (state string, failures, probes int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures, b.probes
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	var changes []string
+	b := newCircuitBreaker(dsl.CircuitBreakerConfig{FailureThreshold: 2, ResetTimeoutMs: 20}, func(from, to string) {
+		changes = append(changes, from+"->"+to)
+	})
+	fail := func() {
+		t.Helper()
+		done, _, err := b.allow("p")
+		if err != nil {
+			t.Fatalf("allow: %v", err)
+		}
+		done(errors.New("boom"))
+	}
+
+	// Invalid input is the caller's fault and does not count
+	for i := 0; i < 2; i++ {
+		done, _, _ := b.allow("p")
+		done(tools.ErrInvalidInput)
+	}
+	if state, failures, _ := breakerState(b); state != BreakerClosed || failures != 0 {
+		t.Fatalf("after invalid input: %s with %d failures", state, failures)
+	}
+
+	fail()
+	if state, failures, _ := breakerState(b); state != BreakerClosed || failures != 1 {
+		t.Fatalf("after one failure: %s with %d failures", state, failures)
+	}
+	fail()
+	if state, _, _ := breakerState(b); state != BreakerOpen {
+		t.Fatalf("after reaching the threshold: %s, want open", state)
+	}
+
+	_, _, openErr := b.allow("p")
+	var open *CircuitOpenError
+	if !errors.As(openErr, &open) || open.RetryAfter <= 0 || open.RetryAfter > 20*time.Millisecond {
+		t.Fatalf("allow on an open breaker = %v, want RetryAfter within the reset timeout", openErr)
+	}
+
+	// After the reset timeout one probe is let through; a failed probe reopens
+	time.Sleep(25 * time.Millisecond)
+	fail()
+	if state, _, _ := breakerState(b); state != BreakerOpen {
+		t.Fatalf("after a failed probe: %s, want open", state)
+	}
+
+	// A successful probe closes the breaker
+	time.Sleep(25 * time.Millisecond)
+	done, _, err := b.allow("p")
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	done(nil)
+	if state, failures, _ := breakerState(b); state != BreakerClosed || failures != 0 {
+		t.Fatalf("after a successful probe: %s with %d failures", state, failures)
+	}
+
+	want := []string{"closed->open", "open->half_open", "half_open->open", "open->half_open", "half_open->closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("transitions = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("transitions = %v, want %v", changes, want)
+			break
+		}
+	}
+}
+
+func TestCircuitBreakerExhaustedProbesReportRetryAfter(t *testing.T) {
+	b := newCircuitBreaker(dsl.CircuitBreakerConfig{FailureThreshold: 1, ResetTimeoutMs: 20}, nil)
+	done, _, _ := b.allow("p")
+	done(errors.New("boom"))
+	time.Sleep(25 * time.Millisecond)
+
+	if _, _, err := b.allow("p"); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	_, _, err := b.allow("p")
+	var open *CircuitOpenError
+	if !errors.As(err, &open) {
+		t.Fatalf("second probe = %v, want CircuitOpenError", err)
+	}
+	if open.RetryAfter <= 0 {
+		t.Errorf("RetryAfter = %v, want a positive delay", open.RetryAfter)
+	}
+}
+
+// halfOpenPolicy returns a policy set whose breaker is half-open with room
+// for two probes, plus the policy itself.
+func halfOpenPolicy(t *testing.T, cfg dsl.PolicyConfig) (*policySet, *toolPolicy) {
+	t.Helper()
+	cfg.Tool = "t"
+	cfg.CircuitBreaker = dsl.CircuitBreakerConfig{FailureThreshold: 1, ResetTimeoutMs: 10, HalfOpenMax: 2}
+	set := newPolicySet([]dsl.PolicyConfig{cfg}, func(string, string, string) {})
+	release, err := set.acquire(context.Background(), "t", nil)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release(errors.New("boom"))
+	time.Sleep(15 * time.Millisecond)
+	return set, set.policies[0]
+}
+
+func TestFailedSlotWaitLeavesHalfOpenBreaker(t *testing.T) {
+	set, p := halfOpenPolicy(t, dsl.PolicyConfig{MaxConcurrent: 1})
+
+	// The first probe takes the only slot
+	release, err := set.acquire(context.Background(), "t", nil)
+	if err != nil {
+		t.Fatalf("first probe: %v", err)
+	}
+	if state, _, _ := breakerState(p.breaker); state != BreakerHalfOpen {
+		t.Fatalf("state = %s, want half_open", state)
+	}
+
+	// The second probe gives up waiting for the slot without calling the tool
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := set.acquire(ctx, "t", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second probe = %v, want a deadline error", err)
+	}
+	if state, _, probes := breakerState(p.breaker); state != BreakerHalfOpen || probes != 1 {
+		t.Fatalf("after a cancelled slot wait: %s with %d probes, want half_open with 1", state, probes)
+	}
+
+	release(nil)
+	if state, _, _ := breakerState(p.breaker); state != BreakerClosed {
+		t.Errorf("after the probe succeeded: %s, want closed", state)
+	}
+}
+
+func TestFailedRateLimitWaitLeavesHalfOpenBreaker(t *testing.T) {
+	set, p := halfOpenPolicy(t, dsl.PolicyConfig{RateLimit: dsl.RateLimitConfig{Rate: 1, Burst: 1}})
+
+	// The opening call used the only token
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := set.acquire(ctx, "t", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire = %v, want a deadline error", err)
+	}
+	if state, _, probes := breakerState(p.breaker); state != BreakerHalfOpen || probes != 0 {
+		t.Errorf("after a cancelled rate limit wait: %s with %d probes, want half_open with 0", state, probes)
+	}
+}
+
+func TestTokenBucketLimitsRate(t *testing.T) {
+	b := newTokenBucket(50, 2)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	// Two tokens are available at once, the third takes 1/50s
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("three calls took %v, want the third to wait about 20ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait on an empty bucket with a cancelled context = %v", err)
+	}
+}
+
+func TestMaxConcurrentLimitsCalls(t *testing.T) {
+	set := newPolicySet([]dsl.PolicyConfig{{Tool: "t", MaxConcurrent: 1}}, nil)
+	release, err := set.acquire(context.Background(), "t", nil)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	second := make(chan func(error), 1)
+	go func() {
+		r, err := set.acquire(context.Background(), "t", nil)
+		if err != nil {
+			t.Errorf("second acquire: %v", err)
+		}
+		second <- r
+	}()
+	select {
+	case <-second:
+		t.Fatal("second call ran while the first held the only slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release(nil)
+	select {
+	case r := <-second:
+		r(nil)
+	case <-time.After(time.Second):
+		t.Fatal("second call was not admitted after the first finished")
+	}
+
+	// Other tools are not limited
+	if _, err := set.acquire(context.Background(), "other", nil); err != nil {
+		t.Errorf("unmatched tool: %v", err)
+	}
+}
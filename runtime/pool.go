@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"context"
+
+	"floe/dsl"
+	"floe/internal/runtime_integration"
+)
+
+// workerPool 限制同时执行的任务步骤数，在工作流的所有 Superstep
+// 与并行分支间共享。slots 为 nil 时不限制。
+type workerPool struct {
+	slots chan struct{}
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{}
+	if size > 0 {
+		p.slots = make(chan struct{}, size)
+	}
+	return p
+}
+
+func (p *workerPool) release() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+// acquireSlot 占用 slots 中的一个位置。没有空位时先发出 step_queued 事件再等待，
+// 等待期间 ctx 到期则返回其错误。
+func (r *WorkflowRuntime) acquireSlot(ctx context.Context, slots chan struct{}, step *dsl.Step) error {
+	if slots == nil {
+		return nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return nil
+	default:
+	}
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventStepQueued, map[string]interface{}{
+		"step_id": step.ID,
+		"status":  "queued",
+	}))
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// usesWorker 判断步骤是否占用工作池。parallel 步骤只负责调度分支，
+// human 步骤只是等待输入，二者都不占用，以免分支因父步骤占位而无法执行。
+func usesWorker(step *dsl.Step) bool {
+	return step.Type != "parallel" && step.Type != "human"
+}
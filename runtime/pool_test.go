@@ -0,0 +1,82 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"floe/dsl"
+	"floe/internal/runtime_integration"
+)
+
+// gaugeTool records the highest number of calls running at the same time.
+type gaugeTool struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (g *gaugeTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	g.mu.Lock()
+	g.running++
+	g.peak = max(g.peak, g.running)
+	g.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	g.mu.Lock()
+	g.running--
+	g.mu.Unlock()
+	return nil, nil
+}
+
+func TestWorkerPoolLimitsConcurrentSteps(t *testing.T) {
+	tests := []struct {
+		name       string
+		workflow   int // workflow max_concurrency
+		parallel   int // parallel step max_concurrency
+		wantPeak   int
+		wantQueued int
+	}{
+		{"unlimited", 0, 0, 4, 0},
+		{"workflow pool", 2, 0, 2, 2},
+		{"parallel step", 0, 1, 1, 3},
+		{"smaller of both", 3, 2, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var branches []dsl.Step
+			for _, id := range []string{"b1", "b2", "b3", "b4"} {
+				branches = append(branches, dsl.Step{ID: id, Type: "task", Tool: "gauge"})
+			}
+			wf := &dsl.Workflow{
+				Name:           "pool",
+				MaxConcurrency: tt.workflow,
+				Steps:          []dsl.Step{{ID: "fan", Type: "parallel", MaxConcurrency: tt.parallel, Branches: branches}},
+			}
+			r := newTestRuntime(t, wf)
+			gauge := &gaugeTool{}
+			r.tools["gauge"] = gauge
+			sub := r.Subscribe(runtime_integration.SubscribeOptions{
+				Types: []runtime_integration.EventType{runtime_integration.EventStepQueued},
+			})
+
+			if err := r.Run(); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if gauge.peak != tt.wantPeak {
+				t.Errorf("peak concurrency = %d, want %d", gauge.peak, tt.wantPeak)
+			}
+			sub.Unsubscribe()
+			queued := 0
+			for e := range sub.Events() {
+				if e.Payload["status"] != "queued" {
+					t.Errorf("step_queued payload = %v", e.Payload)
+				}
+				queued++
+			}
+			if queued != tt.wantQueued {
+				t.Errorf("got %d step_queued events, want %d", queued, tt.wantQueued)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"floe/internal/runtime_integration"
+)
+
+// LoadReplay 读取一次历史运行的事件序列，用于回放。
+// path 可以是 trace.json，也可以是运行目录中的 events.jsonl。
+func LoadReplay(path string) ([]runtime_integration.Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// trace.json 是单个 JSON 对象；events.jsonl 每行一个事件
+	var t Trace
+	if err := json.Unmarshal(data, &t); err == nil && t.Steps != nil {
+		return t.Events(), nil
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, fmt.Errorf("%s is neither a trace nor an event log", path)
+	}
+	events, err := runtime_integration.ReadJSONLFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no events found in %s", path)
+	}
+	return events, nil
+}
+
+// Events 将 trace 还原为运行时会发出的事件序列。
+// 每个 Superstep 内先发出各步骤的 step_start，再按合并顺序发出 step_end；
+// 内存变化由相邻步骤的内存快照推算得到，最后一个步骤与运行结束时的内存对比。
+func (t *Trace) Events() []runtime_integration.Event {
+	var events []runtime_integration.Event
+	add := func(typ runtime_integration.EventType, ts time.Time, payload map[string]interface{}) {
+		events = append(events, runtime_integration.Event{Type: typ, Timestamp: ts, Payload: payload})
+	}
+
+	start := t.StartedAt
+	if start.IsZero() && len(t.Steps) > 0 {
+		start = t.Steps[0].StartedAt
+	}
+	add(runtime_integration.EventWorkflowStarted, start, map[string]interface{}{
+		"workflow_name": t.Workflow,
+		"run_id":        t.RunID,
+	})
+
+	for i := 0; i < len(t.Steps); {
+		// 同一 Superstep 的步骤在 trace 中是连续的
+		j := i
+		for j < len(t.Steps) && t.Steps[j].Superstep == t.Steps[i].Superstep {
+			j++
+		}
+		group := t.Steps[i:j]
+
+		add(runtime_integration.EventSuperstepStart, group[0].StartedAt, map[string]interface{}{
+			"superstep":          group[0].Superstep,
+			"active_steps_count": len(group),
+		})
+
+		started := make([]TraceEvent, 0, len(group))
+		for _, step := range group {
+			if step.Status != "skipped" {
+				started = append(started, step)
+			}
+		}
+		sort.SliceStable(started, func(a, b int) bool {
+			return started[a].StartedAt.Before(started[b].StartedAt)
+		})
+		for _, step := range started {
+			add(runtime_integration.EventStepStart, step.StartedAt, map[string]interface{}{
+				"step_id":         step.StepName,
+				"tool":            step.Tool,
+				"idempotency_key": step.IdempotencyKey,
+				"superstep":       step.Superstep,
+			})
+		}
+
+		for k, step := range group {
+			if step.Status == "skipped" {
+				add(runtime_integration.EventStepSkipped, step.EndedAt, map[string]interface{}{
+					"step_id":   step.StepName,
+					"condition": step.Condition,
+				})
+			}
+			add(runtime_integration.EventStepEnd, step.EndedAt, map[string]interface{}{
+				"step_id":     step.StepName,
+				"status":      step.Status,
+				"output":      step.Output,
+				"error":       step.Error,
+				"error_kind":  step.ErrorKind,
+				"handlers":    step.Handlers,
+				"condition":   step.Condition,
+				"routing":     step.Routing,
+				"compensates": step.Compensates,
+				"cache_hit":   step.CacheHit,
+				"superstep":   step.Superstep,
+				"duration_ms": step.DurationMs,
+				"attempts":    len(step.Attempts),
+				"usage":       step.Usage,
+			})
+			// 最后一个步骤与运行结束时的内存对比
+			after := t.Memory
+			if i+k+1 < len(t.Steps) {
+				after = t.Steps[i+k+1].Input
+			}
+			for _, key := range memoryChanges(step.Input, after) {
+				add(runtime_integration.EventMemoryUpdate, step.EndedAt, map[string]interface{}{
+					"key":   key.path,
+					"value": key.value,
+				})
+			}
+		}
+		i = j
+	}
+
+	end := t.EndedAt
+	if end.IsZero() && len(events) > 0 {
+		end = events[len(events)-1].Timestamp
+	}
+	payload := map[string]interface{}{
+		"status": t.Status,
+		"run_id": t.RunID,
+	}
+	if t.Error != "" {
+		payload["error"] = t.Error
+	}
+	if t.Usage != nil {
+		payload["usage"] = t.Usage
+	}
+	add(runtime_integration.EventWorkflowEnd, end, payload)
+	return events
+}
+
+type memoryChange struct {
+	path  string
+	value interface{}
+}
+
+// memoryChanges 返回 after 中相对 before 新增或改变的叶子值，路径以 . 连接。
+func memoryChanges(before, after map[string]interface{}) []memoryChange {
+	var changes []memoryChange
+	var walk func(prefix string, before, after map[string]interface{})
+	walk = func(prefix string, before, after map[string]interface{}) {
+		keys := make([]string, 0, len(after))
+		for k := range after {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			v := after[k]
+			if nested, ok := v.(map[string]interface{}); ok {
+				old, _ := before[k].(map[string]interface{})
+				walk(path, old, nested)
+				continue
+			}
+			if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+				changes = append(changes, memoryChange{path: path, value: v})
+			}
+		}
+	}
+	walk("", before, after)
+	return changes
+}
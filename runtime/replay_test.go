@@ -0,0 +1,27 @@
+package runtime
+
+import (
+	"testing"
+
+	"floe/internal/runtime_integration"
+)
+
+func TestTraceEventsIncludeLastStepMemoryChanges(t *testing.T) {
+	tr := &Trace{
+		Steps: []TraceEvent{
+			{StepName: "a", Superstep: 1, Input: map[string]interface{}{}},
+			{StepName: "b", Superstep: 2, Input: map[string]interface{}{"global": map[string]interface{}{"a": 1}}},
+		},
+		Memory: map[string]interface{}{"global": map[string]interface{}{"a": 1, "b": 2}},
+	}
+
+	var updates []string
+	for _, e := range tr.Events() {
+		if e.Type == runtime_integration.EventMemoryUpdate {
+			updates = append(updates, e.Payload["key"].(string))
+		}
+	}
+	if len(updates) != 2 || updates[0] != "global.a" || updates[1] != "global.b" {
+		t.Errorf("memory updates = %v, want [global.a global.b]", updates)
+	}
+}
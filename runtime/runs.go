@@ -0,0 +1,190 @@
+package runtime
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"floe/internal/runtime_integration"
+)
+
+// defaultRunsDir 是运行目录的默认根目录。
+const defaultRunsDir = "runs"
+
+// runIndexFile 是运行索引文件名，位于运行目录的根目录下。
+const runIndexFile = "index.jsonl"
+
+// WithRunsDir 设置运行目录的根目录。每次运行写入 <dir>/<workflow>/<run-id>/。
+func WithRunsDir(dir string) Option {
+	return func(r *WorkflowRuntime) {
+		r.runsDir = dir
+	}
+}
+
+// WithTraceOut 指定 trace 的输出路径，替代运行目录中的 trace.json。
+func WithTraceOut(path string) Option {
+	return func(r *WorkflowRuntime) {
+		r.traceOut = path
+	}
+}
+
+// RunRecord 是运行索引中的一条记录。
+type RunRecord struct {
+	RunID     string    `json:"run_id"`
+	Workflow  string    `json:"workflow"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Dir       string    `json:"dir"`
+	Trace     string    `json:"trace"`
+}
+
+// RunDir 返回本次运行的目录。
+func (r *WorkflowRuntime) RunDir() string {
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(r.workflow.Name)
+	if name == "" {
+		name = "workflow"
+	}
+	return filepath.Join(r.runsDir, name, r.runID)
+}
+
+// TracePath 返回 trace 的输出路径。
+func (r *WorkflowRuntime) TracePath() string {
+	if r.traceOut != "" {
+		return r.traceOut
+	}
+	return filepath.Join(r.RunDir(), "trace.json")
+}
+
+// openRun 创建运行目录并打开事件日志。恢复的运行会追加到原有的事件日志。
+func (r *WorkflowRuntime) openRun() error {
+	sink, err := runtime_integration.NewJSONLFileSink(filepath.Join(r.RunDir(), "events.jsonl"))
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	r.events = sink
+	r.sinks = append(r.sinks, sink)
+	return nil
+}
+
+// closeRun 保存 trace、复制产物、写入运行索引并导出 Span。
+func (r *WorkflowRuntime) closeRun() {
+	if r.events != nil {
+		r.sinkMu.Lock()
+		for i, sink := range r.sinks {
+			if sink == r.events {
+				r.sinks = append(r.sinks[:i:i], r.sinks[i+1:]...)
+				break
+			}
+		}
+		r.sinkMu.Unlock()
+		_ = r.events.Close()
+	}
+	if err := r.copyArtifacts(); err != nil {
+		r.log.Warn("failed to copy artifacts", "error", err)
+	}
+	if err := r.SaveTrace(r.TracePath()); err != nil {
+		r.log.Warn("failed to save trace", "error", err)
+	}
+	if err := r.appendRunIndex(); err != nil {
+		r.log.Warn("failed to update run index", "error", err)
+	}
+	if err := r.exportSpans(); err != nil {
+		r.log.Warn("failed to export spans", "error", err)
+	}
+}
+
+// copyArtifacts 将本次运行写出的文件复制到运行目录的 artifacts/ 下。
+func (r *WorkflowRuntime) copyArtifacts() error {
+	root := r.workflow.Tools.Workspace.Root
+	if root == "" {
+		root = "."
+	}
+	seen := make(map[string]bool)
+	for _, a := range r.trace.Artifacts {
+		if seen[a.Path] {
+			continue
+		}
+		seen[a.Path] = true
+		dst := filepath.Join(r.RunDir(), "artifacts", filepath.FromSlash(a.Path))
+		if err := copyFile(filepath.Join(root, filepath.FromSlash(a.Path)), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (r *WorkflowRuntime) appendRunIndex() error {
+	rec := RunRecord{
+		RunID:     r.runID,
+		Workflow:  r.workflow.Name,
+		Status:    r.trace.Status,
+		Error:     r.trace.Error,
+		StartedAt: r.trace.StartedAt,
+		EndedAt:   r.trace.EndedAt,
+		Dir:       r.RunDir(),
+		Trace:     r.TracePath(),
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(r.runsDir, runIndexFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// ListRuns 读取运行索引，按时间顺序返回记录。workflow 不为空时只返回该工作流的运行。
+func ListRuns(runsDir, workflow string) ([]RunRecord, error) {
+	f, err := os.Open(filepath.Join(runsDir, runIndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []RunRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if workflow == "" || rec.Workflow == workflow {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,91 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"floe/dsl"
+)
+
+func TestRunDirLayoutAndIndex(t *testing.T) {
+	runsDir := t.TempDir()
+	run := func(name string, fail bool, opts ...Option) *WorkflowRuntime {
+		t.Helper()
+		wf := &dsl.Workflow{
+			Name:  name,
+			Steps: []dsl.Step{{ID: "work", Type: "task", Tool: "script", Input: map[string]interface{}{"step": "work", "fail": fail}}},
+		}
+		r := newTestRuntime(t, wf, append([]Option{WithRunsDir(runsDir)}, opts...)...)
+		r.tools["script"] = &scriptedTool{}
+		if err := r.Run(); (err != nil) != fail {
+			t.Fatalf("run %s: %v", name, err)
+		}
+		return r
+	}
+
+	ok := run("report", false)
+	failed := run("report", true)
+	traceOut := filepath.Join(t.TempDir(), "custom.json")
+	other := run("../other", false, WithTraceOut(traceOut))
+
+	if want := filepath.Join(runsDir, "report", ok.RunID()); ok.RunDir() != want {
+		t.Errorf("run dir = %s, want %s", ok.RunDir(), want)
+	}
+	for _, name := range []string{"events.jsonl", "trace.json"} {
+		if _, err := os.Stat(filepath.Join(ok.RunDir(), name)); err != nil {
+			t.Errorf("run dir is missing %s: %v", name, err)
+		}
+	}
+	// Workflow names cannot place runs outside the runs dir
+	if !strings.HasPrefix(other.RunDir(), runsDir+string(filepath.Separator)) || strings.Contains(other.RunDir(), "..") {
+		t.Errorf("run dir %s escapes %s", other.RunDir(), runsDir)
+	}
+	if _, err := os.Stat(traceOut); err != nil {
+		t.Errorf("trace was not written to the --trace-out path: %v", err)
+	}
+
+	all, err := ListRuns(runsDir, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("index has %d runs, want 3", len(all))
+	}
+	for i, r := range []*WorkflowRuntime{ok, failed, other} {
+		rec := all[i]
+		if rec.RunID != r.RunID() || rec.Dir != r.RunDir() || rec.Trace != r.TracePath() || rec.Status != r.trace.Status {
+			t.Errorf("record %d = %+v, want run %s with status %s", i, rec, r.RunID(), r.trace.Status)
+		}
+	}
+	if all[1].Status != "failed" || all[1].Error == "" {
+		t.Errorf("failed run record = %+v", all[1])
+	}
+	if all[0].StartedAt.IsZero() || all[0].EndedAt.Before(all[0].StartedAt) {
+		t.Errorf("record times = %v - %v", all[0].StartedAt, all[0].EndedAt)
+	}
+
+	reports, err := ListRuns(runsDir, "report")
+	if err != nil || len(reports) != 2 || reports[0].RunID != ok.RunID() || reports[1].RunID != failed.RunID() {
+		t.Errorf("report runs = %+v (%v), want the two report runs in order", reports, err)
+	}
+}
+
+func TestListRunsSkipsBadLines(t *testing.T) {
+	if runs, err := ListRuns(filepath.Join(t.TempDir(), "missing"), ""); err != nil || runs != nil {
+		t.Errorf("missing index = %v, %v; want no runs and no error", runs, err)
+	}
+
+	dir := t.TempDir()
+	index := `{"run_id":"1","workflow":"a","status":"success"}
+not json
+{"run_id":"2","workflow":"a","status":"fail`
+	if err := os.WriteFile(filepath.Join(dir, runIndexFile), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := ListRuns(dir, "")
+	if err != nil || len(runs) != 1 || runs[0].RunID != "1" {
+		t.Errorf("runs = %+v (%v), want only the complete record", runs, err)
+	}
+}
@@ -1,42 +1,118 @@
 package runtime
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"floe/dsl"
 	"floe/expr"
+	"floe/internal/metrics"
 	"floe/internal/runtime_integration"
 	"floe/memory"
+	"floe/tools"
 )
 
 // WorkflowRuntime 是工作流执行的运行时环境。
 // 它管理工作流的生命周期、内存状态、调度和执行跟踪。
 type WorkflowRuntime struct {
-	workflow  *dsl.Workflow                  // 工作流定义
-	memory    *memory.Memory                 // 全局内存
-	scheduler Scheduler                      // 调度器
-	trace     *Trace                         // 执行跟踪
-	eventChan chan runtime_integration.Event // 事件通道
+	workflow  *dsl.Workflow            // 工作流定义
+	memory    *memory.Memory           // 全局内存
+	scheduler Scheduler                // 调度器
+	trace     *Trace                   // 执行跟踪
+	bus       *runtime_integration.Bus // 事件总线，向所有订阅者分发事件
+
+	executedSteps map[string]bool // 已执行的步骤
+	lastResults   []StepResult    // 上一个 Superstep 的结果
+
+	statePath    string                   // 等待人工输入时持久化运行状态的路径
+	checkpoint   *Checkpoint              // 当前 Superstep 开始时的运行状态
+	humanMu      sync.Mutex               // 保护 pendingHuman 与 answers
+	pendingHuman map[string]*pendingHuman // 等待中的人工步骤
+	answers      map[string]string        // 已收到但尚未被步骤使用的人工输入
+
+	completed []string // 成功完成的步骤，按完成顺序排列，用于失败后的补偿
+
+	policies *policySet   // 工具调用策略（限流、并发、熔断）
+	pool     *workerPool  // 限制同时执行的任务步骤数
+	cache    *resultCache // 步骤结果缓存，nil 表示禁用
+
+	tools map[string]tools.Tool // 本次运行注册的工具，优先于全局工具表
+
+	runID      string         // 运行 ID
+	iterMu     sync.Mutex     // 保护 iterations
+	iterations map[string]int // 每个步骤已执行的次数，用于生成幂等键
+
+	runsDir  string                          // 运行目录的根目录
+	traceOut string                          // trace 输出路径，为空时写入运行目录
+	events   *runtime_integration.JSONLSink  // 运行目录中的事件日志
+	sinks    []runtime_integration.EventSink // 接收所有事件的 Sink
+	sinkMu   sync.Mutex                      // 保证事件按同一顺序写入 Sink 并发布到事件总线
+
+	superstep int // 当前 Superstep 序号
+
+	otlpFile     string // OTLP/JSON Span 输出文件
+	otlpEndpoint string // OTLP HTTP 接收端
+
+	metrics *runtimeMetrics // Prometheus 指标
+
+	log     *slog.Logger // 附带运行上下文并转发为 log 事件的日志记录器
+	baseLog *slog.Logger // 调用方提供的原始日志记录器
+}
+
+// Option 用于配置 WorkflowRuntime。
+type Option func(*WorkflowRuntime)
+
+// WithStateFile 设置运行状态文件。人工步骤等待输入时，运行状态会写入该文件，
+// 以便通过 `floe answer` 提交输入或在进程退出后通过 `floe resume` 恢复。
+// 未设置时写入运行目录中的 state.json。
+func WithStateFile(path string) Option {
+	return func(r *WorkflowRuntime) {
+		r.statePath = path
+	}
 }
 
 // NewRuntime 创建一个新的 WorkflowRuntime 实例。
 // 它会初始化内存，并加载工作流定义的初始变量。
-func NewRuntime(wf *dsl.Workflow) *WorkflowRuntime {
+func NewRuntime(wf *dsl.Workflow, opts ...Option) *WorkflowRuntime {
 	mem := memory.NewMemory()
 	if wf.Memory.Initial != nil {
 		for k, v := range wf.Memory.Initial {
 			mem.Set(k, v)
 		}
 	}
-	return &WorkflowRuntime{
-		workflow:  wf,
-		memory:    mem,
-		scheduler: NewBasicScheduler(wf),
-		trace:     &Trace{Steps: []TraceEvent{}},
-		eventChan: make(chan runtime_integration.Event, 100), // Buffered channel
+	r := &WorkflowRuntime{
+		workflow:      wf,
+		memory:        mem,
+		scheduler:     NewBasicScheduler(wf),
+		trace:         &Trace{Steps: []TraceEvent{}},
+		bus:           runtime_integration.NewBus(),
+		executedSteps: make(map[string]bool),
+		pendingHuman:  make(map[string]*pendingHuman),
+		answers:       make(map[string]string),
+		runID:         newRunID(),
+		iterations:    make(map[string]int),
+		tools:         make(map[string]tools.Tool),
+		runsDir:       defaultRunsDir,
+	}
+	r.policies = newPolicySet(wf.Policies, r.emitBreakerChange)
+	r.pool = newWorkerPool(wf.MaxConcurrency)
+	r.cache = &resultCache{dir: defaultCacheDir}
+	r.metrics = newRuntimeMetrics(metrics.Default)
+	r.setLogger(defaultLogger())
+	for _, opt := range opts {
+		opt(r)
 	}
+	if r.statePath == "" {
+		r.statePath = filepath.Join(r.RunDir(), StateFileName)
+	}
+	if s, ok := r.scheduler.(*BasicScheduler); ok {
+		s.log = r.log
+	}
+	return r
 }
 
 // Workflow returns the workflow definition
@@ -44,34 +120,137 @@ func (r *WorkflowRuntime) Workflow() *dsl.Workflow {
 	return r.workflow
 }
 
-// Subscribe returns a read-only channel for events
-func (r *WorkflowRuntime) Subscribe() <-chan runtime_integration.Event {
-	return r.eventChan
+// Subscribe 注册一个事件订阅者。每个订阅者独立接收事件，
+// 缓冲区满时按 opts.Policy 处理；workflow_end 总是会送达。
+// 订阅应在 Run 之前完成，否则会错过之前的事件。
+func (r *WorkflowRuntime) Subscribe(opts runtime_integration.SubscribeOptions) *runtime_integration.Subscription {
+	return r.bus.Subscribe(opts)
 }
 
-// Emit sends an event to the event channel
+// DroppedEvents 返回因订阅者缓冲区已满而丢弃的事件总数。
+func (r *WorkflowRuntime) DroppedEvents() uint64 {
+	return r.bus.Dropped()
+}
+
+// WithEventSink 添加一个事件 Sink。Sink 接收所有事件，不受事件通道容量影响；
+// 由调用方在 Run 返回后关闭。
+func WithEventSink(sink runtime_integration.EventSink) Option {
+	return func(r *WorkflowRuntime) {
+		r.sinks = append(r.sinks, sink)
+	}
+}
+
+// Emit 将事件写入所有 Sink 并发布到事件总线。两者在同一把锁下完成，
+// Sink 与订阅者看到的事件顺序一致。
 func (r *WorkflowRuntime) Emit(event runtime_integration.Event) {
-	select {
-	case r.eventChan <- event:
-	default:
-		// Drop event if channel is full to avoid blocking runtime
-		fmt.Println("Warning: event channel full, dropping event")
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+	for _, sink := range r.sinks {
+		if err := sink.Write(event); err != nil {
+			// 不经过 r.log，避免写入失败的日志再次写入 Sink
+			r.baseLog.Warn("failed to write event", "run_id", r.runID, "event", event.Type, "error", err)
+		}
+	}
+	r.bus.Publish(event)
+}
+
+// syncSinks 让支持 Sync 的 Sink 将已写出的事件落盘。每个 Superstep 结束时调用一次，
+// 而不是每个事件都落盘。
+func (r *WorkflowRuntime) syncSinks() {
+	r.sinkMu.Lock()
+	sinks := append([]runtime_integration.EventSink(nil), r.sinks...)
+	r.sinkMu.Unlock()
+	for _, sink := range sinks {
+		if s, ok := sink.(interface{ Sync() error }); ok {
+			if err := s.Sync(); err != nil {
+				r.baseLog.Warn("failed to sync event sink", "run_id", r.runID, "error", err)
+			}
+		}
 	}
 }
 
 // Run 开始执行工作流。
 // 它使用 Superstep 模式：调度 -> 执行 -> 合并结果，直到没有更多步骤可执行。
+// 任一步骤最终失败时工作流终止，并返回列出失败步骤的 *WorkflowError。
 func (r *WorkflowRuntime) Run() error {
-	fmt.Printf("Starting workflow: %s\n", r.workflow.Name)
+	if err := r.openRun(); err != nil {
+		r.log.Warn("failed to open run directory", "error", err)
+	}
+	r.trace.RunID = r.runID
+	r.trace.Workflow = r.workflow.Name
+	if r.trace.StartedAt.IsZero() {
+		r.trace.StartedAt = time.Now()
+	}
+
+	r.log.Info("starting workflow", "workflow", r.workflow.Name)
+	r.metrics.runStarted(r.workflow.Name)
 	r.Emit(runtime_integration.NewEvent(runtime_integration.EventWorkflowStarted, map[string]interface{}{
 		"workflow_name": r.workflow.Name,
+		"run_id":        r.runID,
 	}))
 
-	executedSteps := make(map[string]bool)
-	var lastResults []StepResult
+	err := r.run()
+
+	payload := map[string]interface{}{}
+	if err != nil {
+		r.trace.Status = "failed"
+		if errors.Is(err, ErrWorkflowTimeout) || errors.Is(err, ErrSuperstepTimeout) {
+			r.trace.Status = "timed_out"
+		}
+		if errors.Is(err, ErrBudgetExceeded) {
+			r.trace.Status = "budget_exceeded"
+		}
+		r.trace.Error = err.Error()
+		payload["error"] = err.Error()
+		var wfErr *WorkflowError
+		if errors.As(err, &wfErr) {
+			r.trace.FailedSteps = wfErr.FailedSteps
+			r.trace.Compensation = wfErr.Compensation
+			payload["failed_steps"] = wfErr.FailedSteps
+			if wfErr.Compensation != nil {
+				payload["compensation"] = wfErr.Compensation
+			}
+		}
+		r.log.Error("workflow failed", "workflow", r.workflow.Name, "status", r.trace.Status, "error", err)
+	} else {
+		r.trace.Status = "success"
+		r.log.Info("workflow completed", "workflow", r.workflow.Name)
+	}
+	r.trace.EndedAt = time.Now()
+	r.trace.Memory = r.memory.Snapshot()
+	r.trace.DroppedEvents = r.bus.Dropped()
+	if r.trace.DroppedEvents > 0 {
+		r.log.Warn("events were dropped by slow subscribers", "dropped", r.trace.DroppedEvents)
+		payload["dropped_events"] = r.trace.DroppedEvents
+	}
+	payload["status"] = r.trace.Status
+	payload["run_id"] = r.runID
+	if r.trace.Usage != nil {
+		payload["usage"] = r.trace.Usage
+	}
+	r.metrics.runEnded(r.workflow.Name, r.trace.Status, r.trace.DroppedEvents)
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventWorkflowEnd, payload))
+
+	r.closeRun()
+	r.log.Info("trace written", "path", r.TracePath())
+
+	return err
+}
+
+func (r *WorkflowRuntime) run() error {
+	r.configureTools()
+
+	mcpClients, err := r.startMCPServers()
+	if err != nil {
+		return err
+	}
+	defer closeMCPServers(mcpClients)
+
+	ctx, cancel := r.workflowContext()
+	defer cancel()
 
 	for {
-		activeSteps, routingTraces := r.scheduler.NextSteps(r.memory, executedSteps, lastResults)
+		activeSteps, routingTraces := r.scheduler.NextSteps(r.memory, r.executedSteps, r.lastResults)
 
 		// Update routing info in trace for previous steps
 		if len(routingTraces) > 0 {
@@ -87,10 +266,17 @@ func (r *WorkflowRuntime) Run() error {
 		if len(activeSteps) == 0 {
 			break
 		}
+		if ctx.Err() != nil {
+			r.markNotStarted(activeSteps)
+			return r.fail(nil, context.Cause(ctx))
+		}
 
+		r.superstep++
 		r.Emit(runtime_integration.NewEvent(runtime_integration.EventSuperstepStart, map[string]interface{}{
+			"superstep":          r.superstep,
 			"active_steps_count": len(activeSteps),
 		}))
+		r.beginCheckpoint()
 
 		// Filter steps based on 'When' condition
 		var stepsToExecute []dsl.Step
@@ -107,7 +293,7 @@ func (r *WorkflowRuntime) Run() error {
 				conditionTraces[step.ID] = condTrace
 
 				if err != nil {
-					fmt.Printf("Error evaluating condition for step %s: %v\n", step.ID, err)
+					r.log.Error("failed to evaluate condition", "step_id", step.ID, "condition", step.When, "error", err)
 					shouldRun = false
 				} else {
 					shouldRun = result
@@ -122,6 +308,7 @@ func (r *WorkflowRuntime) Run() error {
 					Status:    "skipped",
 					Condition: condTrace,
 				})
+				r.metrics.stepsSkipped.Inc(r.workflow.Name)
 				r.Emit(runtime_integration.NewEvent(runtime_integration.EventStepSkipped, map[string]interface{}{
 					"step_id":   step.ID,
 					"condition": condTrace,
@@ -129,11 +316,17 @@ func (r *WorkflowRuntime) Run() error {
 			}
 		}
 
-		fmt.Printf("Superstep: Executing %d steps (Skipped: %d)...\n", len(stepsToExecute), len(skippedResults))
+		r.log.Info("executing superstep", "steps", len(stepsToExecute), "skipped", len(skippedResults))
 
 		var results []StepResult
+		var timeoutCause error
 		if len(stepsToExecute) > 0 {
-			results = r.runSuperstep(stepsToExecute)
+			sctx, cancelStep := r.superstepContext(ctx)
+			results = r.runSuperstep(sctx, stepsToExecute)
+			if hasTimedOut(results) {
+				timeoutCause = context.Cause(sctx)
+			}
+			cancelStep()
 		}
 
 		results = append(results, skippedResults...)
@@ -151,22 +344,94 @@ func (r *WorkflowRuntime) Run() error {
 			}
 		}
 
-		r.mergeResults(results, executedSteps)
+		r.mergeResults(results, r.executedSteps)
+		r.recordCompleted(results)
+
+		r.lastResults = results
+		r.syncSinks()
 
-		lastResults = results
+		if timeoutCause != nil || len(failedSteps(results)) > 0 {
+			return r.fail(results, timeoutCause)
+		}
+		if err := r.checkBudget(); err != nil {
+			return r.fail(nil, err)
+		}
 	}
 
-	fmt.Println("Workflow completed successfully.")
-	r.Emit(runtime_integration.NewEvent(runtime_integration.EventWorkflowEnd, map[string]interface{}{
-		"status": "success",
-	}))
+	r.clearState()
+	return nil
+}
 
-	// Save trace
-	if err := r.SaveTrace("trace.json"); err != nil {
-		fmt.Printf("Warning: failed to save trace: %v\n", err)
+// fail 结束失败的工作流：执行补偿与 on_error/on_timeout 步骤，返回 *WorkflowError。
+// cause 为超时或超出预算的原因，因步骤失败而终止时为 nil。
+func (r *WorkflowRuntime) fail(results []StepResult, cause error) error {
+	r.clearState()
+	wfErr := &WorkflowError{
+		Workflow:    r.workflow.Name,
+		FailedSteps: failedSteps(results),
+		Cause:       cause,
+	}
+	if wfErr.BudgetExceeded() {
+		// 超出预算只停止调度：不补偿已完成的步骤，也不执行会继续产生用量的 on_error
+		r.log.Error("workflow stopped", "error", cause)
+		return wfErr
+	}
+	if wfErr.TimedOut() {
+		r.log.Error("workflow timed out", "error", cause)
 	}
+	wfErr.Compensation = r.compensate()
+	r.runOnError(wfErr)
+	return wfErr
+}
 
-	return nil
+// runOnError 在工作流失败后执行 on_error 步骤（超时且配置了 on_timeout 时执行 on_timeout）。
+// 失败信息写入内存的 workflow.error，供该步骤通过 ${workflow.error.message} 等路径引用。
+// 该步骤本身的失败只会被记录；预算已用尽时不执行。
+func (r *WorkflowRuntime) runOnError(wfErr *WorkflowError) {
+	id, name := r.workflow.OnError, "on_error"
+	if wfErr.TimedOut() && r.workflow.OnTimeout != "" {
+		id, name = r.workflow.OnTimeout, "on_timeout"
+	}
+	if id == "" {
+		return
+	}
+	step := r.findStepByID(id)
+	if step == nil {
+		r.log.Warn("handler step not found", "handler", name, "step_id", id)
+		return
+	}
+	// 失败的 Superstep 可能同时用尽了预算
+	if err := r.checkBudget(); err != nil {
+		r.log.Warn("skipping handler step", "handler", name, "step_id", id, "error", err)
+		return
+	}
+
+	steps := make([]interface{}, len(wfErr.FailedSteps))
+	failures := make([]interface{}, len(wfErr.FailedSteps))
+	for i, f := range wfErr.FailedSteps {
+		steps[i] = f.StepID
+		failures[i] = map[string]interface{}{
+			"step_id":    f.StepID,
+			"error":      f.Error,
+			"error_kind": f.ErrorKind,
+		}
+	}
+	info := map[string]interface{}{
+		"message":      wfErr.Error(),
+		"steps":        steps,
+		"failed_steps": failures,
+		"timed_out":    wfErr.TimedOut(),
+	}
+	if wfErr.Compensation != nil {
+		info["compensation"] = wfErr.Compensation.Status
+	}
+	_ = r.memory.Set("workflow.error", info)
+
+	r.log.Info("running handler step", "handler", name, "step_id", step.ID)
+	ctx, cancel := r.handlerContext()
+	defer cancel()
+	res := r.executeSingleStep(ctx, step)
+	r.mergeResults([]StepResult{res}, r.executedSteps)
 }
 
 func (r *WorkflowRuntime) mergeResults(results []StepResult, executedSteps map[string]bool) {
@@ -174,35 +439,82 @@ func (r *WorkflowRuntime) mergeResults(results []StepResult, executedSteps map[s
 		executedSteps[res.NodeName] = true
 
 		if res.Err != nil {
-			fmt.Printf("Error in step %s: %v\n", res.NodeName, res.Err)
+			r.log.Error("step failed", "step_id", res.NodeName, "error_kind", res.ErrorKind, "error", res.Err)
+		}
+
+		// Steps that never ran (skipped, not started) end at merge time
+		now := time.Now()
+		if res.EndedAt.IsZero() {
+			res.EndedAt = now
+		}
+		if res.StartedAt.IsZero() {
+			res.StartedAt = res.EndedAt
 		}
+		duration := res.EndedAt.Sub(res.StartedAt).Milliseconds()
 
 		// Emit Step End Event
 		r.Emit(runtime_integration.NewEvent(runtime_integration.EventStepEnd, map[string]interface{}{
-			"step_id":   res.NodeName,
-			"status":    res.Status,
-			"output":    res.Output,
-			"error":     res.ErrorMsg,
-			"condition": res.Condition,
-			"routing":   res.Routing,
+			"step_id":     res.NodeName,
+			"status":      res.Status,
+			"output":      res.Output,
+			"error":       res.ErrorMsg,
+			"error_kind":  res.ErrorKind,
+			"handlers":    res.Handlers,
+			"condition":   res.Condition,
+			"routing":     res.Routing,
+			"compensates": res.Compensates,
+			"cache_hit":   res.CacheHit,
+			"superstep":   r.superstep,
+			"duration_ms": duration,
+			"attempts":    len(res.Attempts),
+			"usage":       res.Usage,
 		}))
 
 		// Record Trace
+		var tool string
+		if step := r.findStepByID(res.NodeName); step != nil {
+			tool = step.Tool
+		}
 		r.trace.Steps = append(r.trace.Steps, TraceEvent{
-			StepName:  res.NodeName,
-			Input:     r.memory.Snapshot(),
-			Output:    res.Output,
-			Messages:  res.Messages,
-			Timestamp: time.Now(),
-			Error:     res.ErrorMsg,
-			Retries:   res.Retries,
-			Strategy:  res.Strategy,
-			Fallback:  res.Fallback,
-			Ignored:   res.Ignored,
-			Status:    res.Status,
-			Condition: res.Condition,
-			Routing:   res.Routing,
+			StepName:       res.NodeName,
+			Tool:           tool,
+			Input:          r.memory.Snapshot(),
+			Output:         res.Output,
+			Messages:       res.Messages,
+			Timestamp:      now,
+			Error:          res.ErrorMsg,
+			ErrorKind:      res.ErrorKind,
+			Retries:        res.Retries,
+			Strategy:       res.Strategy,
+			Fallback:       res.Fallback,
+			Ignored:        res.Ignored,
+			Handlers:       res.Handlers,
+			Status:         res.Status,
+			Condition:      res.Condition,
+			Routing:        res.Routing,
+			Rendered:       res.Rendered,
+			Artifacts:      res.Artifacts,
+			Compensates:    res.Compensates,
+			CacheHit:       res.CacheHit,
+			IdempotencyKey: res.IdempotencyKey,
+			Superstep:      r.superstep,
+			StartedAt:      res.StartedAt,
+			EndedAt:        res.EndedAt,
+			DurationMs:     duration,
+			ResolvedInput:  res.ResolvedInput,
+			Attempts:       res.Attempts,
+			Usage:          res.Usage,
 		})
+		if res.Usage != nil {
+			// 总量以新值替换，避免修改检查点中共享的旧值
+			var total tools.Usage
+			if r.trace.Usage != nil {
+				total = *r.trace.Usage
+			}
+			total = total.Add(*res.Usage)
+			r.trace.Usage = &total
+		}
+		r.trace.Artifacts = append(r.trace.Artifacts, res.Artifacts...)
 
 		if res.Output != nil {
 			step := r.findStepByID(res.NodeName)
@@ -240,15 +552,35 @@ func (r *WorkflowRuntime) findStepByID(id string) *dsl.Step {
 }
 
 // executeParallel is used by superstep.go for legacy "parallel" step types
-func (r *WorkflowRuntime) executeParallel(step *dsl.Step) error {
+func (r *WorkflowRuntime) executeParallel(ctx context.Context, step *dsl.Step) error {
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(step.Branches))
 
+	// Per-step branch limit, applied on top of the workflow worker pool
+	var limit chan struct{}
+	if step.MaxConcurrency > 0 {
+		limit = make(chan struct{}, step.MaxConcurrency)
+	}
+
 	for _, branch := range step.Branches {
 		wg.Add(1)
 		go func(b dsl.Step) {
 			defer wg.Done()
-			res := r.executeSingleStep(&b)
+			if err := r.acquireSlot(ctx, limit, &b); err != nil {
+				errChan <- err
+				return
+			}
+			if limit != nil {
+				defer func() { <-limit }()
+			}
+			res := r.executeSingleStep(ctx, &b)
+			// Branch usage and artifacts count towards the parallel step
+			if res.Usage != nil {
+				tools.RecordUsage(ctx, *res.Usage)
+			}
+			for _, a := range res.Artifacts {
+				tools.RecordArtifact(ctx, a)
+			}
 			if res.Err != nil {
 				errChan <- res.Err
 				return
@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"fmt"
+	"log/slog"
 
 	"floe/dsl"
 	"floe/expr"
@@ -14,10 +15,29 @@ type Scheduler interface {
 
 type BasicScheduler struct {
 	workflow *dsl.Workflow
+	reserved map[string]bool // 仅在特定时机执行的步骤 (如 on_error)，不参与顺序执行
+	log      *slog.Logger
 }
 
 func NewBasicScheduler(wf *dsl.Workflow) *BasicScheduler {
-	return &BasicScheduler{workflow: wf}
+	return &BasicScheduler{workflow: wf, reserved: reservedSteps(wf), log: slog.Default()}
+}
+
+// reservedSteps 返回由运行时在特定时机执行的步骤：on_error、on_timeout 与补偿步骤。
+func reservedSteps(wf *dsl.Workflow) map[string]bool {
+	reserved := make(map[string]bool)
+	if wf.OnError != "" {
+		reserved[wf.OnError] = true
+	}
+	if wf.OnTimeout != "" {
+		reserved[wf.OnTimeout] = true
+	}
+	for _, step := range wf.Steps {
+		if step.Compensate != "" {
+			reserved[step.Compensate] = true
+		}
+	}
+	return reserved
 }
 
 // NextSteps 决定下一个 Superstep 应该执行哪些步骤。
@@ -25,9 +45,10 @@ func (s *BasicScheduler) NextSteps(mem *memory.Memory, executedSteps map[string]
 	var nextSteps []dsl.Step
 	routingTraces := make(map[string]*RoutingTrace)
 
-	// 1. Check for Fallbacks from last superstep
+	// 1. Check for Fallbacks from last superstep. A step whose chain already
+	// ran the fallback in place succeeded and only records it.
 	for _, res := range lastResults {
-		if res.Fallback != "" {
+		if res.Fallback != "" && res.Err != nil {
 			fallbackStep := s.findStep(res.Fallback)
 			if fallbackStep != nil {
 				nextSteps = append(nextSteps, *fallbackStep)
@@ -70,7 +91,7 @@ func (s *BasicScheduler) NextSteps(mem *memory.Memory, executedSteps map[string]
 				}
 
 				if err != nil {
-					fmt.Printf("Error resolving next for step %s: %v\n", currentStep.ID, err)
+					s.log.Error("failed to resolve next step", "step_id", currentStep.ID, "error", err)
 					continue
 				}
 
@@ -82,8 +103,14 @@ func (s *BasicScheduler) NextSteps(mem *memory.Memory, executedSteps map[string]
 				} else {
 					// No explicit next, try sequential fallback
 					idx := s.findStepIndex(res.NodeName)
-					if idx != -1 && idx+1 < len(s.workflow.Steps) {
-						nextStep := &s.workflow.Steps[idx+1]
+					if idx != -1 {
+						idx++
+						for idx < len(s.workflow.Steps) && s.reserved[s.workflow.Steps[idx].ID] {
+							idx++
+						}
+					}
+					if idx != -1 && idx < len(s.workflow.Steps) {
+						nextStep := &s.workflow.Steps[idx]
 						// Only add if not executed
 						if !executedSteps[nextStep.ID] {
 							nextSteps = append(nextSteps, *nextStep)
@@ -117,7 +144,7 @@ func (s *BasicScheduler) resolveNext(step *dsl.Step, mem *memory.Memory) (string
 		for k, v := range norm.Map {
 			matched, err := expr.EvaluateBool(k, mem)
 			if err != nil {
-				fmt.Printf("Error evaluating route condition '%s': %v\n", k, err)
+				s.log.Error("failed to evaluate route condition", "step_id", step.ID, "condition", k, "error", err)
 				continue
 			}
 			if matched {
@@ -24,9 +24,23 @@ type StepResult struct {
 	Status    string          // executed | skipped
 	Condition *ConditionTrace // Condition trace info
 	Routing   *RoutingTrace   // Routing trace info
+	Rendered  map[string]string
+	Artifacts []tools.Artifact
+	ErrorKind string   // Classified error kind (timeout, rate_limited, ...)
+	Handlers  []string // Error handlers that fired, in order
+	EndedAt   time.Time
+	// Compensates is the step this result rolls back, for compensation steps
+	Compensates string
+	CacheHit    bool // Output was served from the result cache
+	// IdempotencyKey is shared by all attempts of this execution
+	IdempotencyKey string
+	StartedAt      time.Time
+	ResolvedInput  map[string]interface{} // Input actually passed to the tool
+	Attempts       []AttemptTrace
+	Usage          *tools.Usage // Usage reported by tools, summed over attempts
 }
 
-func (r *WorkflowRuntime) runSuperstep(steps []dsl.Step) []StepResult {
+func (r *WorkflowRuntime) runSuperstep(ctx context.Context, steps []dsl.Step) []StepResult {
 	var wg sync.WaitGroup
 	results := make([]StepResult, len(steps))
 
@@ -34,7 +48,7 @@ func (r *WorkflowRuntime) runSuperstep(steps []dsl.Step) []StepResult {
 		wg.Add(1)
 		go func(idx int, s dsl.Step) {
 			defer wg.Done()
-			res := r.executeSingleStep(&s)
+			res := r.executeSingleStep(ctx, &s)
 			results[idx] = res
 		}(i, step)
 	}
@@ -43,125 +57,340 @@ func (r *WorkflowRuntime) runSuperstep(steps []dsl.Step) []StepResult {
 	return results
 }
 
-func (r *WorkflowRuntime) executeSingleStep(step *dsl.Step) StepResult {
+func (r *WorkflowRuntime) executeSingleStep(ctx context.Context, step *dsl.Step) StepResult {
+	res := r.executeStep(ctx, step, nil)
+	r.metrics.stepEnded(step.Tool, res)
+	return res
+}
+
+// chainState 记录一条错误处理链的执行进度。
+type chainState struct {
+	idx      int // 当前处理器
+	attempts int // 当前 retry 处理器已用的重试次数
+}
+
+// executeStep 执行步骤并按错误处理链处理失败。
+// fallbackPath 是就地执行 Fallback 时的调用路径，用于避免循环。
+// ctx 到期（工作流或 Superstep 超时）时步骤被取消并标记为 timed_out，不再经过错误处理链。
+func (r *WorkflowRuntime) executeStep(ctx context.Context, step *dsl.Step, fallbackPath []string) (res StepResult) {
+	// Inline fallbacks run within the slot of the step they replace
+	if fallbackPath == nil && usesWorker(step) {
+		if err := r.acquireSlot(ctx, r.pool.slots, step); err != nil {
+			return timedOutResult(ctx, step)
+		}
+		defer r.pool.release()
+	}
+
+	key := r.idempotencyKey(step.ID, r.nextIteration(step.ID))
+	ctx = tools.WithIdempotencyKey(ctx, key)
+
 	r.Emit(runtime_integration.NewEvent(runtime_integration.EventStepStart, map[string]interface{}{
-		"step_id": step.ID,
-		"tool":    step.Tool,
+		"step_id":         step.ID,
+		"tool":            step.Tool,
+		"idempotency_key": key,
+		"superstep":       r.superstep,
 	}))
 
-	var finalErr error
 	var output interface{}
-	var messages map[string]interface{}
-
+	var rendered map[string]string
+	var errorKind string
+	var handlers []string
+	var cacheHit bool
+	var resolved map[string]interface{}
+	var history []AttemptTrace
+	startedAt := time.Now()
+	rec := &tools.Recorder{}
 	retries := 0
-	maxRetries := step.Error.Retries
-	delay := time.Duration(step.Error.DelayMs) * time.Millisecond
+	defer func() {
+		res.NodeName = step.ID
+		if res.Status == "" {
+			res.Status = "executed"
+		}
+		res.Retries = retries
+		res.Rendered = rendered
+		res.Artifacts = rec.Artifacts()
+		res.ErrorKind = errorKind
+		res.Handlers = handlers
+		res.EndedAt = time.Now()
+		res.CacheHit = cacheHit
+		res.IdempotencyKey = key
+		res.StartedAt = startedAt
+		res.ResolvedInput = resolved
+		res.Attempts = history
+		res.Usage = rec.Usage()
+	}()
+
 	timeout := time.Duration(step.Error.TimeoutMs) * time.Millisecond
-	if timeout == 0 {
-		timeout = 24 * time.Hour // Default no timeout (effectively)
-	}
+	ttl, ttlErr := cacheTTL(step)
+
+	states := make(map[string]*chainState)
 
+attempts:
 	for {
 		// 1. Resolve Inputs
-		input := make(map[string]interface{})
-		for k, v := range step.Input {
-			if strVal, ok := v.(string); ok {
-				input[k] = r.memory.ResolveInterpolation(strVal)
-			} else {
-				input[k] = v
+		attemptStart := time.Now()
+		call := &toolCall{}
+		input, renderedInput, err := r.resolveInput(step)
+		rendered = renderedInput
+		resolved = input
+		if err == nil {
+			err = ttlErr
+		}
+
+		// 2. Serve from cache when enabled
+		useCache := err == nil && ttl > 0 && r.cache != nil
+		var mem map[string]interface{}
+		if useCache {
+			mem = r.cacheMemory(step, input)
+			if entry, ok := r.cache.get(step.Tool, input, mem); ok {
+				output, cacheHit = entry.Output, true
+				recordInto(rec, entry.Artifacts, entry.Usage)
+				history = append(history, newAttempt(len(history)+1, attemptStart, nil))
+				history[len(history)-1].CacheHit = true
+				break
 			}
 		}
 
-		// 2. Execute with Timeout
-		var err error
-		output, err = r.runWithTimeout(step, input, timeout)
+		// 3. Execute with Timeout
+		if err == nil {
+			// Each call records separately so a cached result carries only its own artifacts and usage
+			callRec := &tools.Recorder{}
+			output, err = r.runWithTimeout(ctx, step, input, timeout, callRec, call)
+			recordInto(rec, callRec.Artifacts(), callRec.Usage())
+			if err == nil && useCache {
+				entry := cacheEntry{Output: output, Artifacts: callRec.Artifacts(), Usage: callRec.Usage()}
+				if cerr := r.cache.put(step.Tool, input, mem, entry, ttl); cerr != nil {
+					r.log.Warn("failed to cache step result", "step_id", step.ID, "error", cerr)
+				}
+			}
+		}
+
+		history = append(history, newAttempt(len(history)+1, attemptStart, err))
+		history[len(history)-1].Tool = call.trace(time.Now())
 
 		if err == nil {
 			// Success
-			finalErr = nil
 			break
 		}
 
-		// Handle Error
-		action := handleError(step, err)
+		if ctx.Err() != nil {
+			errorKind = ErrorKindTimeout
+			return timedOutResult(ctx, step)
+		}
 
-		if action.Type == ActionRetry {
-			if retries < maxRetries {
-				retries++
-				time.Sleep(delay)
-				continue
-			}
-			// Retries exhausted. Check if fallback is configured.
-			if step.Error.Fallback != "" {
+		// 4. Handle Error through the handler chain
+		errorKind = classifyError(err)
+		chain, chained, key := errorHandlers(step.Error, errorKind)
+		state, ok := states[key]
+		if !ok {
+			state = &chainState{}
+			states[key] = state
+		}
+
+		reason := ""
+		for ; state.idx < len(chain); state.idx, state.attempts = state.idx+1, 0 {
+			h := chain[state.idx]
+			action := handleError(h)
+
+			switch action.Type {
+			case ActionRetry:
+				if !shouldRetry(h, errorKind) {
+					reason = fmt.Sprintf("%s error is not retryable", errorKind)
+					continue
+				}
+				if !r.retryAllowed(step, h, input) {
+					reason = fmt.Sprintf("tool '%s' is not idempotent", step.Tool)
+					continue
+				}
+				if state.attempts < h.Retries {
+					state.attempts++
+					retries++
+					handlers = append(handlers, "retry")
+					delay := retryDelay(h, state.attempts, err)
+					history[len(history)-1].DelayMs = delay.Milliseconds()
+					if sleepCtx(ctx, delay) != nil {
+						errorKind = ErrorKindTimeout
+						return timedOutResult(ctx, step)
+					}
+					continue attempts
+				}
+				reason = "max retries exceeded"
+
+			case ActionIgnore:
+				handlers = append(handlers, "ignore")
 				return StepResult{
-					NodeName: step.ID,
-					Err:      fmt.Errorf("max retries exceeded, triggering fallback: %w", err),
-					Fallback: step.Error.Fallback,
-					Strategy: "retry-fallback",
+					Output:   h.DefaultOutput,
+					Err:      nil, // Clear error so runtime continues
+					Ignored:  true,
+					ErrorMsg: err.Error(),
+					Strategy: "ignore",
+				}
+
+			case ActionFallback:
+				handlers = append(handlers, "fallback:"+action.FallbackStepName)
+				if r.findStepByID(action.FallbackStepName) == nil {
+					// An unresolvable fallback cannot recover the step, so it fails
+					err = fmt.Errorf("fallback step '%s' not found: %w", action.FallbackStepName, err)
+					reason = "fallback failed"
+					continue
+				}
+				if !chained {
+					// Schedule the fallback step for the next superstep
+					strategy := "fallback"
+					prefix := "fallback triggered"
+					if state.idx > 0 {
+						strategy = "retry-fallback"
+						prefix = reason + ", triggering fallback"
+					}
+					return StepResult{
+						Err:      fmt.Errorf("%s: %w", prefix, err),
+						Fallback: action.FallbackStepName,
+						Strategy: strategy,
+						ErrorMsg: err.Error(),
+					}
+				}
+				// Within a chain the fallback step runs in place of this step
+				fbRes, fbErr := r.runFallbackInline(ctx, step, action.FallbackStepName, fallbackPath)
+				// The fallback's tokens, cost and files count towards this step
+				recordInto(rec, fbRes.Artifacts, fbRes.Usage)
+				if fbErr == nil {
+					return StepResult{
+						Output:   fbRes.Output,
+						Messages: r.resolveMessages(step),
+						Fallback: action.FallbackStepName,
+						Strategy: "fallback",
+						ErrorMsg: err.Error(),
+					}
+				}
+				err = fmt.Errorf("fallback '%s' failed: %w", action.FallbackStepName, fbErr)
+				reason = "fallback failed"
+
+			default:
+				handlers = append(handlers, "fail")
+				return StepResult{
+					Err:      err,
+					Strategy: "fail",
 					ErrorMsg: err.Error(),
-					Retries:  retries,
-					Status:   "executed",
 				}
 			}
-			// Fall through to fail
-			finalErr = fmt.Errorf("max retries exceeded: %w", err)
-		} else if action.Type == ActionIgnore {
-			// Ignore error
-			return StepResult{
-				NodeName: step.ID,
-				Err:      nil, // Clear error so runtime continues
-				Ignored:  true,
-				ErrorMsg: err.Error(),
-				Strategy: "ignore",
-				Status:   "executed",
-			}
-		} else if action.Type == ActionFallback {
-			// Return fallback step name
-			return StepResult{
-				NodeName: step.ID,
-				Err:      fmt.Errorf("fallback triggered: %w", err),
-				Fallback: action.FallbackStepName,
-				Strategy: "fallback",
-				ErrorMsg: err.Error(),
-				Status:   "executed",
-			}
-		} else {
-			// Fail
-			finalErr = err
 		}
-		break
-	}
 
-	if finalErr != nil {
+		// Handler chain exhausted
+		finalErr := err
+		if reason != "" {
+			finalErr = fmt.Errorf("%s: %w", reason, err)
+		}
+		handlers = append(handlers, "fail")
 		return StepResult{
-			NodeName: step.ID,
 			Err:      finalErr,
-			Retries:  retries,
 			Strategy: "fail",
 			ErrorMsg: finalErr.Error(),
-			Status:   "executed",
 		}
 	}
 
-	// 4. Resolve Messages
-	messages = make(map[string]interface{})
+	// 5. Resolve Messages
+	return StepResult{
+		Output:   output,
+		Messages: r.resolveMessages(step),
+		Err:      nil,
+	}
+}
+
+// runFallbackInline 就地执行 Fallback 步骤，返回其结果。
+func (r *WorkflowRuntime) runFallbackInline(ctx context.Context, step *dsl.Step, fallbackID string, path []string) (StepResult, error) {
+	path = append(append([]string(nil), path...), step.ID)
+	for _, id := range path {
+		if id == fallbackID {
+			return StepResult{}, fmt.Errorf("fallback cycle detected: %v -> %s", path, fallbackID)
+		}
+	}
+	fb := r.findStepByID(fallbackID)
+	if fb == nil {
+		return StepResult{}, fmt.Errorf("fallback step '%s' not found", fallbackID)
+	}
+	res := r.executeStep(ctx, fb, path)
+	r.Emit(runtime_integration.NewEvent(runtime_integration.EventStepEnd, map[string]interface{}{
+		"step_id":    res.NodeName,
+		"status":     res.Status,
+		"output":     res.Output,
+		"error":      res.ErrorMsg,
+		"error_kind": res.ErrorKind,
+		"handlers":   res.Handlers,
+		"usage":      res.Usage,
+		"inline_for": step.ID,
+	}))
+	if res.Err != nil {
+		return res, res.Err
+	}
+	return res, nil
+}
+
+func (r *WorkflowRuntime) resolveMessages(step *dsl.Step) map[string]interface{} {
+	messages := make(map[string]interface{})
 	for k, v := range step.Messages {
 		messages[k] = r.memory.ResolveInterpolation(v)
 	}
+	return messages
+}
 
-	return StepResult{
-		NodeName: step.ID,
-		Output:   output,
-		Messages: messages,
-		Err:      nil,
-		Retries:  retries,
-		Status:   "executed",
+// resolveInput 解析步骤输入：字符串做 ${} 插值，{template: "..."} 形式的值
+// 以内存快照为数据渲染 Go text/template。渲染结果同时返回，用于记录到 trace。
+func (r *WorkflowRuntime) resolveInput(step *dsl.Step) (map[string]interface{}, map[string]string, error) {
+	input := make(map[string]interface{})
+	var rendered map[string]string
+
+	for k, v := range step.Input {
+		switch val := v.(type) {
+		case string:
+			input[k] = r.memory.ResolveInterpolation(val)
+		case map[string]interface{}:
+			text, ok := val["template"].(string)
+			if !ok || len(val) != 1 {
+				input[k] = v
+				continue
+			}
+			out, err := tools.RenderTemplate(text, r.memory.Snapshot())
+			if err != nil {
+				return nil, rendered, fmt.Errorf("input '%s': %w", k, err)
+			}
+			if rendered == nil {
+				rendered = make(map[string]string)
+			}
+			rendered[k] = out
+			input[k] = out
+		default:
+			input[k] = r.resolveValue(v)
+		}
+	}
+
+	return input, rendered, nil
+}
+
+// resolveValue 对列表中的字符串元素做 ${} 插值，例如 shell 工具的 args。
+func (r *WorkflowRuntime) resolveValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return r.memory.ResolveInterpolation(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = r.resolveValue(item)
+		}
+		return out
+	default:
+		return v
 	}
 }
 
-func (r *WorkflowRuntime) runWithTimeout(step *dsl.Step, input map[string]interface{}, timeout time.Duration) (interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+// runWithTimeout 执行步骤的一次尝试。timeout 为 0 时只受 parent 的截止时间约束。
+// 工具调用的实际起止时间记录在 call 中。
+func (r *WorkflowRuntime) runWithTimeout(parent context.Context, step *dsl.Step, input map[string]interface{}, timeout time.Duration, rec *tools.Recorder, call *toolCall) (interface{}, error) {
+	ctx, cancel := context.WithCancel(parent)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	}
 	defer cancel()
+	ctx = tools.WithRecorder(tools.WithMemory(ctx, r.memory.Snapshot()), rec)
 
 	type result struct {
 		val interface{}
@@ -170,21 +399,34 @@ func (r *WorkflowRuntime) runWithTimeout(step *dsl.Step, input map[string]interf
 	ch := make(chan result, 1)
 
 	go func() {
-		// 2. Get Tool (only if not parallel)
+		// 2. Get Tool (only if not parallel or human)
 		if step.Type == "parallel" {
-			err := r.executeParallel(step)
+			err := r.executeParallel(ctx, step)
 			ch <- result{nil, err}
 			return
 		}
+		if step.Type == "human" {
+			out, err := r.waitForHuman(ctx, step)
+			ch <- result{out, err}
+			return
+		}
 
-		tool, err := tools.Get(step.Tool)
+		tool, err := r.tool(step.Tool)
 		if err != nil {
 			ch <- result{nil, err}
 			return
 		}
 
-		// 3. Execute Tool
+		// 3. Execute Tool under the matching call policies
+		release, err := r.policies.acquire(ctx, step.Tool, input)
+		if err != nil {
+			ch <- result{nil, err}
+			return
+		}
+		call.begin()
 		out, err := tool.Run(ctx, input)
+		call.finish()
+		release(err)
 		ch <- result{out, err}
 	}()
 
@@ -0,0 +1,71 @@
+package runtime
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"floe/dsl"
+)
+
+// inputTool returns the input it was called with.
+type inputTool struct{}
+
+func (inputTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	return input, nil
+}
+
+func TestTemplateInputsAreRenderedAndTraced(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name:   "templates",
+		Memory: dsl.MemoryConfig{Initial: map[string]interface{}{"items": []interface{}{"a", "b"}, "name": "floe"}},
+		Steps: []dsl.Step{{
+			ID: "render", Type: "task", Tool: "input", Output: "global.input",
+			Input: map[string]interface{}{
+				"body":  map[string]interface{}{"template": `{{.name}}: {{join ", " .items}}`},
+				"plain": "hello ${name}",
+				// A map with more keys than template is passed through
+				"map": map[string]interface{}{"template": "{{.name}}", "other": 1},
+			},
+		}},
+	}
+	r := newTestRuntime(t, wf)
+	r.tools["input"] = inputTool{}
+	if err := r.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, _ := r.memory.Get("global.input")
+	want := map[string]interface{}{
+		"body":  "floe: a, b",
+		"plain": "hello floe",
+		"map":   map[string]interface{}{"template": "{{.name}}", "other": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tool input = %#v, want %#v", got, want)
+	}
+	step := traceStep(r, "render")
+	if step == nil || !reflect.DeepEqual(step.Rendered, map[string]string{"body": "floe: a, b"}) {
+		t.Errorf("trace rendered = %+v, want only body", step)
+	}
+}
+
+func TestBadTemplateFailsStepAsInvalidInput(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name: "bad_template",
+		Steps: []dsl.Step{{
+			ID: "render", Type: "task", Tool: "input",
+			Input: map[string]interface{}{"body": map[string]interface{}{"template": "{{if}}"}},
+			Error: dsl.ErrorConfig{Strategy: "retry", Retries: 2},
+		}},
+	}
+	r := newTestRuntime(t, wf)
+	r.tools["input"] = inputTool{}
+	if err := r.Run(); err == nil {
+		t.Fatal("run succeeded")
+	}
+	step := traceStep(r, "render")
+	if step == nil || step.ErrorKind != ErrorKindInvalidInput || step.Retries != 0 {
+		t.Errorf("step trace = %+v, want an invalid_input failure without retries", step)
+	}
+}
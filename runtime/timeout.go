@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"floe/dsl"
+)
+
+var (
+	// ErrWorkflowTimeout 表示整个工作流超过了 timeout_ms。
+	ErrWorkflowTimeout = errors.New("workflow timeout exceeded")
+	// ErrSuperstepTimeout 表示某个 Superstep 超过了 superstep_timeout_ms。
+	ErrSuperstepTimeout = errors.New("superstep timeout exceeded")
+	// ErrHandlerTimeout 表示补偿或 on_error/on_timeout 步骤超过了 handler_timeout_ms。
+	ErrHandlerTimeout = errors.New("handler timeout exceeded")
+)
+
+// defaultHandlerTimeout 是未配置 handler_timeout_ms 时补偿与失败处理步骤的时间上限。
+const defaultHandlerTimeout = time.Minute
+
+// workflowContext 返回带工作流截止时间的 context。
+func (r *WorkflowRuntime) workflowContext() (context.Context, context.CancelFunc) {
+	if r.workflow.TimeoutMs <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	d := time.Duration(r.workflow.TimeoutMs) * time.Millisecond
+	return context.WithTimeoutCause(context.Background(), d, ErrWorkflowTimeout)
+}
+
+// superstepContext 在工作流 context 上叠加 Superstep 的时间上限。
+func (r *WorkflowRuntime) superstepContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.workflow.SuperstepTimeoutMs <= 0 {
+		return context.WithCancel(ctx)
+	}
+	d := time.Duration(r.workflow.SuperstepTimeoutMs) * time.Millisecond
+	return context.WithTimeoutCause(ctx, d, ErrSuperstepTimeout)
+}
+
+// handlerContext 返回补偿与 on_error/on_timeout 步骤使用的 context。
+// 这些步骤在工作流 context 结束后运行，因此有独立的截止时间。
+func (r *WorkflowRuntime) handlerContext() (context.Context, context.CancelFunc) {
+	d := defaultHandlerTimeout
+	if r.workflow.HandlerTimeoutMs > 0 {
+		d = time.Duration(r.workflow.HandlerTimeoutMs) * time.Millisecond
+	}
+	return context.WithTimeoutCause(context.Background(), d, ErrHandlerTimeout)
+}
+
+// timedOutResult 返回因截止时间到期而被取消的步骤结果。
+func timedOutResult(ctx context.Context, step *dsl.Step) StepResult {
+	err := fmt.Errorf("step cancelled: %w", context.Cause(ctx))
+	return StepResult{
+		NodeName:  step.ID,
+		Err:       err,
+		ErrorMsg:  err.Error(),
+		ErrorKind: ErrorKindTimeout,
+		Status:    "timed_out",
+		EndedAt:   time.Now(),
+	}
+}
+
+// sleepCtx 等待 d，ctx 先到期时返回其错误。
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// hasTimedOut 判断结果中是否有因超时被取消的步骤。
+func hasTimedOut(results []StepResult) bool {
+	for _, res := range results {
+		if res.Status == "timed_out" {
+			return true
+		}
+	}
+	return false
+}
+
+// markNotStarted 将截止时间到期时已被调度、但尚未开始的步骤以 timed_out 状态记入 trace。
+// 未被选中的条件分支和只作为 Fallback 的步骤不会被记录。
+func (r *WorkflowRuntime) markNotStarted(steps []dsl.Step) {
+	var remaining []StepResult
+	for _, step := range steps {
+		if r.executedSteps[step.ID] {
+			continue
+		}
+		remaining = append(remaining, StepResult{
+			NodeName: step.ID,
+			Status:   "timed_out",
+			ErrorMsg: "not started before timeout",
+		})
+	}
+	r.mergeResults(remaining, r.executedSteps)
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"floe/dsl"
+)
+
+// slowTool blocks until its context ends, or for a long time if it never does.
+type slowTool struct{}
+
+func (slowTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(5 * time.Second):
+		return "late", nil
+	}
+}
+
+func traceStep(r *WorkflowRuntime, id string) *TraceEvent {
+	for i := range r.trace.Steps {
+		if r.trace.Steps[i].StepName == id {
+			return &r.trace.Steps[i]
+		}
+	}
+	return nil
+}
+
+func TestStepTimeoutFailsWithTimeoutKind(t *testing.T) {
+	wf := &dsl.Workflow{
+		Name:  "step_timeout",
+		Steps: []dsl.Step{{ID: "slow", Type: "task", Tool: "slow", Error: dsl.ErrorConfig{TimeoutMs: 20}}},
+	}
+	r := newTestRuntime(t, wf)
+	r.tools["slow"] = slowTool{}
+
+	err := r.Run()
+	var wfErr *WorkflowError
+	if !errors.As(err, &wfErr) || wfErr.TimedOut() {
+		t.Fatalf("run error = %v, want a step failure that is not a workflow timeout", err)
+	}
+	if r.trace.Status != "failed" {
+		t.Errorf("status = %s, want failed", r.trace.Status)
+	}
+	if s := traceStep(r, "slow"); s == nil || s.ErrorKind != ErrorKindTimeout {
+		t.Errorf("step trace = %+v, want error kind timeout", s)
+	}
+}
+
+func TestWorkflowDeadlines(t *testing.T) {
+	tests := []struct {
+		name  string
+		wf    dsl.Workflow
+		cause error
+	}{
+		{"workflow", dsl.Workflow{TimeoutMs: 30}, ErrWorkflowTimeout},
+		{"superstep", dsl.Workflow{SuperstepTimeoutMs: 30}, ErrSuperstepTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := tt.wf
+			wf.Name = "deadline"
+			wf.Steps = []dsl.Step{
+				{ID: "slow", Type: "task", Tool: "slow", Next: "after"},
+				{ID: "after", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "after"}},
+			}
+			r := newTestRuntime(t, &wf)
+			log := &callLog{}
+			r.tools["slow"] = slowTool{}
+			r.tools["log"] = log
+
+			start := time.Now()
+			err := r.Run()
+			if time.Since(start) > 2*time.Second {
+				t.Errorf("run took %v, the deadline did not cancel the step", time.Since(start))
+			}
+			var wfErr *WorkflowError
+			if !errors.As(err, &wfErr) || !wfErr.TimedOut() || !errors.Is(err, tt.cause) {
+				t.Fatalf("run error = %v, want a timeout caused by %v", err, tt.cause)
+			}
+			if !strings.Contains(err.Error(), tt.cause.Error()) {
+				t.Errorf("error %q does not name the cause %q", err, tt.cause)
+			}
+			if r.trace.Status != "timed_out" {
+				t.Errorf("status = %s, want timed_out", r.trace.Status)
+			}
+			if s := traceStep(r, "slow"); s == nil || s.Status != "timed_out" {
+				t.Errorf("step trace = %+v, want status timed_out", s)
+			}
+			if len(log.steps) != 0 {
+				t.Errorf("steps ran after the deadline: %v", log.steps)
+			}
+		})
+	}
+}
+
+func TestOnTimeoutTakesPrecedenceOverOnError(t *testing.T) {
+	tests := []struct {
+		name string
+		tool string
+		want string
+	}{
+		{"timeout", "slow", "on_timeout"},
+		{"failure", "paid", "on_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := &dsl.Workflow{
+				Name:      "handlers",
+				TimeoutMs: 30,
+				OnError:   "on_error",
+				OnTimeout: "on_timeout",
+				Steps: []dsl.Step{
+					{ID: "work", Type: "task", Tool: tt.tool, Input: map[string]interface{}{"fail": true}},
+					{ID: "on_error", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "on_error"}},
+					{ID: "on_timeout", Type: "task", Tool: "log", Input: map[string]interface{}{"step": "on_timeout"}},
+				},
+			}
+			r := newTestRuntime(t, wf)
+			log := &callLog{}
+			r.tools["slow"] = slowTool{}
+			r.tools["paid"] = paidTool{}
+			r.tools["log"] = log
+
+			if err := r.Run(); err == nil {
+				t.Fatal("run succeeded")
+			}
+			if len(log.steps) != 1 || log.steps[0] != tt.want {
+				t.Errorf("handlers run = %v, want [%s]", log.steps, tt.want)
+			}
+		})
+	}
+}
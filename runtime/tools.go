@@ -0,0 +1,31 @@
+package runtime
+
+import "floe/tools"
+
+// configureTools 按工作流的 tools 配置创建需要配置的内置工具，并注册到本次运行的工具表，
+// 不影响同一进程中的其他运行。
+func (r *WorkflowRuntime) configureTools() {
+	shell := r.workflow.Tools.Shell
+	r.tools["shell"] = tools.NewShellTool(tools.ShellConfig{
+		Enabled:        shell.Enabled,
+		Allow:          shell.Allow,
+		Dir:            shell.Dir,
+		Env:            shell.Env,
+		AllowEnv:       shell.AllowEnv,
+		Secrets:        shell.Secrets,
+		MaxOutputBytes: shell.MaxOutputBytes,
+	})
+
+	for name, tool := range tools.FileTools(r.workflow.Tools.Workspace.Root) {
+		r.tools[name] = tool
+	}
+}
+
+// tool 按名称查找工具。本次运行注册的工具（按配置创建的内置工具、MCP 工具）优先于全局工具表。
+// 运行期间的工具在调度任何步骤之前注册，之后只读。
+func (r *WorkflowRuntime) tool(name string) (tools.Tool, error) {
+	if t, ok := r.tools[name]; ok {
+		return t, nil
+	}
+	return tools.Get(name)
+}
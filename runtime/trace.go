@@ -2,28 +2,129 @@ package runtime
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
+
+	"floe/tools"
 )
 
 type Trace struct {
-	Steps []TraceEvent `json:"steps"`
+	RunID     string    `json:"run_id,omitempty"`
+	Workflow  string    `json:"workflow,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+	EndedAt   time.Time `json:"ended_at,omitempty"`
+
+	Status       string                 `json:"status,omitempty"`       // success | failed | timed_out | budget_exceeded
+	Error        string                 `json:"error,omitempty"`        // 工作流失败原因
+	FailedSteps  []StepFailure          `json:"failed_steps,omitempty"` // 导致失败的步骤
+	Compensation *CompensationReport    `json:"compensation,omitempty"` // 失败后的补偿结果
+	Steps        []TraceEvent           `json:"steps"`
+	Artifacts    []tools.Artifact       `json:"artifacts,omitempty"` // 本次运行写出的所有文件
+	Memory       map[string]interface{} `json:"memory,omitempty"`    // 运行结束时的内存
+
+	DroppedEvents uint64       `json:"dropped_events,omitempty"` // 因订阅者缓冲区已满而丢弃的事件数
+	Usage         *tools.Usage `json:"usage,omitempty"`          // 本次运行的 token 与费用总量
 }
 
 type TraceEvent struct {
 	StepName  string                 `json:"step_name"`
+	Tool      string                 `json:"tool,omitempty"` // 步骤使用的工具
 	Input     map[string]interface{} `json:"input"`
 	Output    interface{}            `json:"output"`
 	Messages  map[string]interface{} `json:"messages,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
-	Error     string                 `json:"error,omitempty"`    // 错误信息
-	Retries   int                    `json:"retries,omitempty"`  // 重试次数
-	Strategy  string                 `json:"strategy,omitempty"` // 错误处理策略
-	Fallback  string                 `json:"fallback,omitempty"` // Fallback 步骤
-	Ignored   bool                   `json:"ignored,omitempty"`  // 是否忽略错误
-	Status    string                 `json:"status,omitempty"`   // executed | skipped
+	Error     string                 `json:"error,omitempty"`      // 错误信息
+	ErrorKind string                 `json:"error_kind,omitempty"` // 错误类型
+	Retries   int                    `json:"retries,omitempty"`    // 重试次数
+	Strategy  string                 `json:"strategy,omitempty"`   // 错误处理策略
+	Fallback  string                 `json:"fallback,omitempty"`   // Fallback 步骤
+	Ignored   bool                   `json:"ignored,omitempty"`    // 是否忽略错误
+	Handlers  []string               `json:"handlers,omitempty"`   // 依次触发的错误处理器
+	Status    string                 `json:"status,omitempty"`     // executed | skipped
 	Condition *ConditionTrace        `json:"condition,omitempty"`
 	Routing   *RoutingTrace          `json:"routing,omitempty"`
+	Rendered  map[string]string      `json:"rendered,omitempty"`  // 模板渲染后的输入
+	Artifacts []tools.Artifact       `json:"artifacts,omitempty"` // 步骤写出的文件
+
+	Compensates string `json:"compensates,omitempty"` // 补偿步骤所撤销的步骤
+	CacheHit    bool   `json:"cache_hit,omitempty"`   // 输出是否来自缓存
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // 传给工具的幂等键
+
+	Superstep     int                    `json:"superstep"`                // 所属 Superstep 序号，从 1 开始
+	StartedAt     time.Time              `json:"started_at"`               // 步骤开始时间
+	EndedAt       time.Time              `json:"ended_at"`                 // 步骤结束时间
+	DurationMs    int64                  `json:"duration_ms"`              // 步骤耗时 (毫秒)
+	ResolvedInput map[string]interface{} `json:"resolved_input,omitempty"` // 实际传给工具的输入
+	Attempts      []AttemptTrace         `json:"attempts,omitempty"`       // 每次尝试的记录
+	Usage         *tools.Usage           `json:"usage,omitempty"`          // 工具上报的 token 与费用
+}
+
+// AttemptTrace 记录步骤的一次执行尝试。
+type AttemptTrace struct {
+	Attempt    int       `json:"attempt"`              // 第几次尝试，从 1 开始
+	StartedAt  time.Time `json:"started_at"`           // 尝试开始时间
+	DurationMs int64     `json:"duration_ms"`          // 尝试耗时 (毫秒)
+	Error      string    `json:"error,omitempty"`      // 尝试失败的原因
+	ErrorKind  string    `json:"error_kind,omitempty"` // 错误类型
+	DelayMs    int64     `json:"delay_ms,omitempty"`   // 下一次重试前的等待时间 (毫秒)
+	CacheHit   bool      `json:"cache_hit,omitempty"`  // 结果来自缓存
+
+	Tool *ToolCallTrace `json:"tool,omitempty"` // 工具调用的起止时间，未调用工具时为空
+}
+
+// ToolCallTrace 记录一次工具调用的实际起止时间，不含等待调用策略的时间。
+type ToolCallTrace struct {
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"` // 超时放弃等待时为放弃的时间
+}
+
+// toolCall 在执行工具的 goroutine 中记录调用时间；超时后该 goroutine 可能仍在运行。
+type toolCall struct {
+	mu         sync.Mutex
+	start, end time.Time
+}
+
+func (c *toolCall) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start = time.Now()
+}
+
+func (c *toolCall) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.end = time.Now()
+}
+
+// trace 返回调用的起止时间；调用尚未结束时以 now 作为结束时间。
+func (c *toolCall) trace(now time.Time) *ToolCallTrace {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.start.IsZero() {
+		return nil
+	}
+	end := c.end
+	if end.IsZero() {
+		end = now
+	}
+	return &ToolCallTrace{StartedAt: c.start, EndedAt: end}
+}
+
+func newAttempt(n int, start time.Time, err error) AttemptTrace {
+	at := AttemptTrace{
+		Attempt:    n,
+		StartedAt:  start,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		at.Error = err.Error()
+		at.ErrorKind = classifyError(err)
+	}
+	return at
 }
 
 type ConditionTrace struct {
@@ -41,5 +142,23 @@ func (r *WorkflowRuntime) SaveTrace(path string) error {
 	if err != nil {
 		return err
 	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
 	return os.WriteFile(path, data, 0644)
 }
+
+// LoadTrace 读取 SaveTrace 写出的 trace 文件。
+func LoadTrace(path string) (*Trace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t Trace
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("invalid trace file %s: %w", path, err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,316 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"floe/tools"
+)
+
+// 超过该行数的输出不再逐行对比，直接整体替换，避免 LCS 的平方开销
+const maxDiffLines = 2000
+
+// TraceDiff 描述两次运行之间的差异，A 为基准运行，B 为对比运行。
+type TraceDiff struct {
+	A        RunSummary  `json:"a"`
+	B        RunSummary  `json:"b"`
+	Run      []FieldDiff `json:"run,omitempty"`      // 运行级别的差异
+	Steps    []StepDiff  `json:"steps"`              // 按步骤与迭代对齐后的结果
+	Diverged string      `json:"diverged,omitempty"` // 第一个行为不同的步骤
+}
+
+// RunSummary 是参与对比的一次运行的概要。
+type RunSummary struct {
+	RunID      string       `json:"run_id,omitempty"`
+	Workflow   string       `json:"workflow,omitempty"`
+	Status     string       `json:"status,omitempty"`
+	DurationMs int64        `json:"duration_ms"`
+	Usage      *tools.Usage `json:"usage,omitempty"`
+}
+
+// StepDiff 是同一步骤同一次迭代在两次运行中的对比结果。
+type StepDiff struct {
+	Step      string      `json:"step"`
+	Iteration int         `json:"iteration"`        // 第几次执行该步骤，从 1 开始
+	Change    string      `json:"change"`           // same | changed | only_a | only_b
+	Fields    []FieldDiff `json:"fields,omitempty"` // 不同的字段，不含耗时
+	// 耗时总会有抖动，单独给出而不计入 Change
+	DurationMsA int64 `json:"duration_ms_a"`
+	DurationMsB int64 `json:"duration_ms_b"`
+}
+
+// FieldDiff 是一个字段在两次运行中的取值。
+type FieldDiff struct {
+	Field    string      `json:"field"`
+	A        interface{} `json:"a"`
+	B        interface{} `json:"b"`
+	TextDiff []string    `json:"text_diff,omitempty"` // 逐行对比，行首为 "  "、"- " 或 "+ "
+}
+
+// Label 返回步骤名，迭代多于一次时带上迭代序号。
+func (d StepDiff) Label() string {
+	if d.Iteration > 1 {
+		return fmt.Sprintf("%s#%d", d.Step, d.Iteration)
+	}
+	return d.Step
+}
+
+// Changed 报告两次运行是否有行为上的差异。耗时不计入。
+func (d *TraceDiff) Changed() bool {
+	return len(d.Run) > 0 || d.Diverged != ""
+}
+
+// DiffTraces 对比两次运行。步骤按名称和迭代序号对齐，
+// 比较状态、错误、条件结果、路由决策、输出与用量，并给出两边的耗时。
+func DiffTraces(a, b *Trace) *TraceDiff {
+	d := &TraceDiff{
+		A:     summarizeRun(a),
+		B:     summarizeRun(b),
+		Steps: []StepDiff{},
+	}
+	d.Run = appendField(d.Run, "status", a.Status, b.Status)
+	d.Run = appendField(d.Run, "error", a.Error, b.Error)
+	d.Run = appendUsage(d.Run, a.Usage, b.Usage)
+
+	stepsA := keySteps(a.Steps)
+	stepsB := keySteps(b.Steps)
+	for _, key := range mergeStepKeys(stepsA, stepsB) {
+		ea, inA := stepsA.events[key]
+		eb, inB := stepsB.events[key]
+		sd := StepDiff{Step: key.step, Iteration: key.iteration}
+		switch {
+		case !inB:
+			sd.Change = "only_a"
+			sd.DurationMsA = ea.DurationMs
+		case !inA:
+			sd.Change = "only_b"
+			sd.DurationMsB = eb.DurationMs
+		default:
+			sd.DurationMsA, sd.DurationMsB = ea.DurationMs, eb.DurationMs
+			sd.Fields = diffStep(ea, eb)
+			sd.Change = "same"
+			if len(sd.Fields) > 0 {
+				sd.Change = "changed"
+			}
+		}
+		if sd.Change != "same" && d.Diverged == "" {
+			d.Diverged = sd.Label()
+		}
+		d.Steps = append(d.Steps, sd)
+	}
+	return d
+}
+
+func summarizeRun(t *Trace) RunSummary {
+	s := RunSummary{
+		RunID:    t.RunID,
+		Workflow: t.Workflow,
+		Status:   t.Status,
+		Usage:    t.Usage,
+	}
+	if !t.StartedAt.IsZero() && !t.EndedAt.IsZero() {
+		s.DurationMs = t.EndedAt.Sub(t.StartedAt).Milliseconds()
+	}
+	return s
+}
+
+// stepKey 标识一个步骤的第几次执行
+type stepKey struct {
+	step      string
+	iteration int
+}
+
+type keyedSteps struct {
+	order  []stepKey
+	events map[stepKey]TraceEvent
+}
+
+func keySteps(steps []TraceEvent) keyedSteps {
+	ks := keyedSteps{events: make(map[stepKey]TraceEvent)}
+	seen := make(map[string]int)
+	for _, e := range steps {
+		seen[e.StepName]++
+		key := stepKey{step: e.StepName, iteration: seen[e.StepName]}
+		ks.order = append(ks.order, key)
+		ks.events[key] = e
+	}
+	return ks
+}
+
+// mergeStepKeys 以 A 的顺序为准，把只在 B 中出现的步骤插到它在 B 中前一个步骤之后
+func mergeStepKeys(a, b keyedSteps) []stepKey {
+	merged := append([]stepKey(nil), a.order...)
+	index := func(k stepKey) int {
+		for i, m := range merged {
+			if m == k {
+				return i
+			}
+		}
+		return -1
+	}
+	for i, key := range b.order {
+		if _, ok := a.events[key]; ok {
+			continue
+		}
+		pos := 0
+		if i > 0 {
+			pos = index(b.order[i-1]) + 1
+		}
+		merged = append(merged, stepKey{})
+		copy(merged[pos+1:], merged[pos:])
+		merged[pos] = key
+	}
+	return merged
+}
+
+func diffStep(a, b TraceEvent) []FieldDiff {
+	var fields []FieldDiff
+	fields = appendField(fields, "status", stepStatus(a), stepStatus(b))
+	fields = appendField(fields, "tool", a.Tool, b.Tool)
+	fields = appendField(fields, "error_kind", a.ErrorKind, b.ErrorKind)
+	fields = appendField(fields, "error", a.Error, b.Error)
+	fields = appendField(fields, "condition", conditionResult(a.Condition), conditionResult(b.Condition))
+	fields = appendField(fields, "routing", routingResult(a.Routing), routingResult(b.Routing))
+	fields = appendField(fields, "retries", a.Retries, b.Retries)
+	fields = appendField(fields, "fallback", a.Fallback, b.Fallback)
+	if !reflect.DeepEqual(a.Output, b.Output) {
+		fields = append(fields, FieldDiff{
+			Field:    "output",
+			A:        a.Output,
+			B:        b.Output,
+			TextDiff: diffLines(outputText(a.Output), outputText(b.Output)),
+		})
+	}
+	return appendUsage(fields, a.Usage, b.Usage)
+}
+
+func appendField(fields []FieldDiff, name string, a, b interface{}) []FieldDiff {
+	if reflect.DeepEqual(a, b) {
+		return fields
+	}
+	return append(fields, FieldDiff{Field: name, A: a, B: b})
+}
+
+// appendUsage 只在至少一边上报了用量时比较模型、token 与费用
+func appendUsage(fields []FieldDiff, a, b *tools.Usage) []FieldDiff {
+	if a == nil && b == nil {
+		return fields
+	}
+	var ua, ub tools.Usage
+	if a != nil {
+		ua = *a
+	}
+	if b != nil {
+		ub = *b
+	}
+	fields = appendField(fields, "model", ua.Model, ub.Model)
+	fields = appendField(fields, "tokens", ua.TotalTokens(), ub.TotalTokens())
+	return appendField(fields, "cost", ua.Cost, ub.Cost)
+}
+
+// stepStatus 把跳过、失败与成功区分开；被忽略的错误仍算执行成功
+func stepStatus(e TraceEvent) string {
+	switch {
+	case e.Status == "skipped":
+		return "skipped"
+	case e.Error != "" && !e.Ignored:
+		return "failed"
+	case e.Status != "":
+		return e.Status
+	}
+	return "executed"
+}
+
+func conditionResult(c *ConditionTrace) interface{} {
+	if c == nil {
+		return nil
+	}
+	return c.Result
+}
+
+func routingResult(r *RoutingTrace) interface{} {
+	if r == nil {
+		return nil
+	}
+	return r.Result
+}
+
+// outputText 把输出转成便于逐行对比的文本：字符串原样使用，其余格式化为 JSON
+func outputText(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// diffLines 基于最长公共子序列逐行对比两段文本
+func diffLines(a, b string) []string {
+	la := splitLines(a)
+	lb := splitLines(b)
+
+	if len(la) > maxDiffLines || len(lb) > maxDiffLines {
+		out := make([]string, 0, len(la)+len(lb))
+		for _, l := range la {
+			out = append(out, "- "+l)
+		}
+		for _, l := range lb {
+			out = append(out, "+ "+l)
+		}
+		return out
+	}
+
+	// lcs[i][j] 为 la[i:] 与 lb[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(la)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(lb)+1)
+	}
+	for i := len(la) - 1; i >= 0; i-- {
+		for j := len(lb) - 1; j >= 0; j-- {
+			if la[i] == lb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(la) && j < len(lb) {
+		switch {
+		case la[i] == lb[j]:
+			out = append(out, "  "+la[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+la[i])
+			i++
+		default:
+			out = append(out, "+ "+lb[j])
+			j++
+		}
+	}
+	for ; i < len(la); i++ {
+		out = append(out, "- "+la[i])
+	}
+	for ; j < len(lb); j++ {
+		out = append(out, "+ "+lb[j])
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
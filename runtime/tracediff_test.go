@@ -0,0 +1,164 @@
+package runtime
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stepChanges summarizes a diff as "label:change[:field,...]" per step.
+func stepChanges(d *TraceDiff) []string {
+	out := []string{}
+	for _, s := range d.Steps {
+		entry := s.Label() + ":" + s.Change
+		if len(s.Fields) > 0 {
+			var names []string
+			for _, f := range s.Fields {
+				names = append(names, f.Field)
+			}
+			entry += ":" + strings.Join(names, ",")
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+func TestDiffTraces(t *testing.T) {
+	step := func(name string, output interface{}) TraceEvent {
+		return TraceEvent{StepName: name, Tool: "t", Output: output}
+	}
+	tests := []struct {
+		name     string
+		a, b     []TraceEvent
+		want     []string
+		diverged string
+	}{
+		{
+			name: "empty traces",
+			want: []string{},
+		},
+		{
+			name: "same steps",
+			a:    []TraceEvent{step("a", "x"), step("b", 1.0)},
+			b:    []TraceEvent{step("a", "x"), step("b", 1.0)},
+			want: []string{"a:same", "b:same"},
+		},
+		{
+			name:     "changed output",
+			a:        []TraceEvent{step("a", "x"), step("b", map[string]interface{}{"n": 1.0})},
+			b:        []TraceEvent{step("a", "x"), step("b", map[string]interface{}{"n": 2.0})},
+			want:     []string{"a:same", "b:changed:output"},
+			diverged: "b",
+		},
+		{
+			name:     "added step is placed after its predecessor in B",
+			a:        []TraceEvent{step("a", nil), step("c", nil)},
+			b:        []TraceEvent{step("a", nil), step("b", nil), step("c", nil)},
+			want:     []string{"a:same", "b:only_b", "c:same"},
+			diverged: "b",
+		},
+		{
+			name:     "added first step",
+			a:        []TraceEvent{step("b", nil)},
+			b:        []TraceEvent{step("a", nil), step("b", nil)},
+			want:     []string{"a:only_b", "b:same"},
+			diverged: "a",
+		},
+		{
+			name:     "removed step",
+			a:        []TraceEvent{step("a", nil), step("b", nil)},
+			b:        []TraceEvent{step("a", nil)},
+			want:     []string{"a:same", "b:only_a"},
+			diverged: "b",
+		},
+		{
+			name:     "extra iteration",
+			a:        []TraceEvent{step("loop", 1.0), step("loop", 2.0)},
+			b:        []TraceEvent{step("loop", 1.0), step("loop", 3.0), step("loop", 4.0)},
+			want:     []string{"loop:same", "loop#2:changed:output", "loop#3:only_b"},
+			diverged: "loop#2",
+		},
+		{
+			name:     "status, error and routing",
+			a:        []TraceEvent{{StepName: "a", Routing: &RoutingTrace{Result: "b"}}},
+			b:        []TraceEvent{{StepName: "a", Error: "boom", ErrorKind: "fatal", Routing: &RoutingTrace{Result: "c"}}},
+			want:     []string{"a:changed:status,error_kind,error,routing"},
+			diverged: "a",
+		},
+		{
+			name:     "ignored error still counts as executed",
+			a:        []TraceEvent{{StepName: "a"}},
+			b:        []TraceEvent{{StepName: "a", Status: "executed", Error: "boom", Ignored: true}},
+			want:     []string{"a:changed:error"},
+			diverged: "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiffTraces(&Trace{Steps: tt.a}, &Trace{Steps: tt.b})
+			if got := stepChanges(d); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("steps = %v, want %v", got, tt.want)
+			}
+			if d.Diverged != tt.diverged {
+				t.Errorf("diverged = %q, want %q", d.Diverged, tt.diverged)
+			}
+			if d.Changed() != (tt.diverged != "") {
+				t.Errorf("Changed() = %v", d.Changed())
+			}
+		})
+	}
+}
+
+func TestDiffTracesRunFieldsAndDurations(t *testing.T) {
+	a := &Trace{Status: "success", Steps: []TraceEvent{{StepName: "a", DurationMs: 10}}}
+	b := &Trace{Status: "failed", Error: "boom", Steps: []TraceEvent{{StepName: "a", DurationMs: 99}}}
+	d := DiffTraces(a, b)
+
+	var fields []string
+	for _, f := range d.Run {
+		fields = append(fields, f.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"status", "error"}) {
+		t.Errorf("run fields = %v, want [status error]", fields)
+	}
+	// Durations are reported but do not make a step differ
+	s := d.Steps[0]
+	if s.Change != "same" || s.DurationMsA != 10 || s.DurationMsB != 99 {
+		t.Errorf("step = %+v, want same with durations 10 and 99", s)
+	}
+	if !d.Changed() || d.Diverged != "" {
+		t.Errorf("Changed() = %v, diverged = %q", d.Changed(), d.Diverged)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"both empty", "", "", nil},
+		{"added text", "", "x\ny", []string{"+ x", "+ y"}},
+		{"removed text", "x\ny\n", "", []string{"- x", "- y"}},
+		{"same", "x\ny", "x\ny\n", []string{"  x", "  y"}},
+		{"changed middle line", "a\nb\nc", "a\nB\nc", []string{"  a", "- b", "+ B", "  c"}},
+		{"inserted line", "a\nc", "a\nb\nc", []string{"  a", "+ b", "  c"}},
+		{"removed line", "a\nb\nc", "a\nc", []string{"  a", "- b", "  c"}},
+		{"moved line keeps the longest common run", "a\nb\nc\nd", "b\nc\nd\na", []string{"- a", "  b", "  c", "  d", "+ a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesReplacesLongTextWhole(t *testing.T) {
+	long := strings.Repeat("same\n", maxDiffLines+1)
+	got := diffLines(long, long+"more")
+	if len(got) != 2*(maxDiffLines+1)+1 || got[0] != "- same" || got[len(got)-1] != "+ more" {
+		t.Errorf("got %d lines starting %q, want the whole of A removed and B added", len(got), got[0])
+	}
+}
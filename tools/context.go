@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type ctxKey int
+
+const (
+	memoryKey ctxKey = iota
+	recorderKey
+	idempotencyKey
+)
+
+// WithMemory attaches a read-only memory snapshot to the context so that
+// tools which render or query workflow state can access it.
+func WithMemory(ctx context.Context, snapshot map[string]interface{}) context.Context {
+	return context.WithValue(ctx, memoryKey, snapshot)
+}
+
+// MemoryFromContext returns the memory snapshot attached by the runtime, or nil.
+func MemoryFromContext(ctx context.Context) map[string]interface{} {
+	snapshot, _ := ctx.Value(memoryKey).(map[string]interface{})
+	return snapshot
+}
+
+// WithIdempotencyKey attaches the idempotency key of the current step execution.
+// The key is the same for every retry of the execution and survives a resume,
+// so tools with side effects can pass it on to deduplicate requests.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey, key)
+}
+
+// IdempotencyKey returns the idempotency key attached by the runtime, or "".
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey).(string)
+	return key
+}
+
+// Artifact is a file produced by a tool during a step.
+type Artifact struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Usage is the resource consumption of a tool call, such as the tokens and
+// cost of an LLM request. Cost is in whatever currency the tool reports,
+// typically USD.
+type Usage struct {
+	Model        string  `json:"model,omitempty"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost,omitempty"`
+}
+
+// TotalTokens returns input plus output tokens.
+func (u Usage) TotalTokens() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Add returns the sum of u and o. Different models are listed comma-separated.
+func (u Usage) Add(o Usage) Usage {
+	sum := Usage{
+		Model:        u.Model,
+		InputTokens:  u.InputTokens + o.InputTokens,
+		OutputTokens: u.OutputTokens + o.OutputTokens,
+		Cost:         u.Cost + o.Cost,
+	}
+	for _, model := range strings.Split(o.Model, ",") {
+		if model == "" || slices.Contains(strings.Split(sum.Model, ","), model) {
+			continue
+		}
+		if sum.Model == "" {
+			sum.Model = model
+		} else {
+			sum.Model += "," + model
+		}
+	}
+	return sum
+}
+
+// Recorder collects side information reported by tools while a step runs,
+// in addition to the tool's output value.
+type Recorder struct {
+	mu        sync.Mutex
+	artifacts []Artifact
+	usage     *Usage
+}
+
+// Artifacts returns the artifacts recorded so far.
+func (r *Recorder) Artifacts() []Artifact {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Artifact(nil), r.artifacts...)
+}
+
+// Usage returns the usage recorded so far, summed over all calls, or nil if
+// no tool reported any.
+func (r *Recorder) Usage() *Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.usage == nil {
+		return nil
+	}
+	u := *r.usage
+	return &u
+}
+
+// WithRecorder attaches a recorder to the context.
+func WithRecorder(ctx context.Context, rec *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey, rec)
+}
+
+// RecordArtifact reports a written file. It is a no-op without a recorder.
+func RecordArtifact(ctx context.Context, a Artifact) {
+	rec, _ := ctx.Value(recorderKey).(*Recorder)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.artifacts = append(rec.artifacts, a)
+}
+
+// RecordUsage reports the usage of a call. Tools that call models should
+// report usage even when the call fails, since the tokens were still spent.
+// It is a no-op without a recorder.
+func RecordUsage(ctx context.Context, u Usage) {
+	rec, _ := ctx.Value(recorderKey).(*Recorder)
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var sum Usage
+	if rec.usage != nil {
+		sum = *rec.usage
+	}
+	sum = sum.Add(u)
+	rec.usage = &sum
+}
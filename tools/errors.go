@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrRetryable marks errors that may succeed when the call is retried.
+	ErrRetryable = errors.New("retryable")
+	// ErrFatal marks errors that will not succeed no matter how often the call is retried.
+	ErrFatal = errors.New("fatal")
+	// ErrInvalidInput marks errors caused by bad step input. It is also fatal.
+	ErrInvalidInput = errors.New("invalid input")
+)
+
+// kindError attaches one or more sentinel kinds to an error without changing its message.
+type kindError struct {
+	kinds []error
+	err   error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return append(append([]error{}, e.kinds...), e.err)
+}
+
+// Retryable marks err as retryable.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kinds: []error{ErrRetryable}, err: err}
+}
+
+// Fatal marks err as not retryable.
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kinds: []error{ErrFatal}, err: err}
+}
+
+// InvalidInput returns a fatal error describing bad tool input.
+func InvalidInput(format string, args ...interface{}) error {
+	return &kindError{kinds: []error{ErrInvalidInput, ErrFatal}, err: fmt.Errorf(format, args...)}
+}
+
+// RateLimitError reports that a call was rejected by a rate limit. RetryAfter
+// is the server's hint for when to try again (zero when unknown).
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited (retry after %s): %v", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("rate limited: %v", e.Err)
+}
+
+func (e *RateLimitError) Unwrap() []error {
+	return []error{ErrRetryable, e.Err}
+}
+
+// RateLimited returns a retryable error carrying a retry-after hint.
+func RateLimited(retryAfter time.Duration, err error) error {
+	return &RateLimitError{RetryAfter: retryAfter, Err: err}
+}
@@ -0,0 +1,360 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Workspace confines file tools to a root directory.
+type Workspace struct {
+	root string
+}
+
+// NewWorkspace creates a workspace rooted at root ("." when empty).
+func NewWorkspace(root string) *Workspace {
+	if root == "" {
+		root = "."
+	}
+	return &Workspace{root: root}
+}
+
+// Resolve maps a workspace-relative path to a host path, rejecting any path
+// that escapes the root, including through symlinks.
+func (w *Workspace) Resolve(p string) (string, error) {
+	root, err := filepath.Abs(w.root)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(p) {
+		return "", InvalidInput("path '%s' must be relative to the workspace", p)
+	}
+	full := filepath.Join(root, filepath.FromSlash(p))
+	if !within(root, full) {
+		return "", InvalidInput("path '%s' escapes the workspace", p)
+	}
+
+	// Resolve symlinks on the longest existing prefix
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("workspace root: %w", err)
+	}
+	existing := full
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if !within(realRoot, real) {
+		return "", InvalidInput("path '%s' escapes the workspace", p)
+	}
+	return full, nil
+}
+
+// Rel returns the workspace-relative, slash-separated form of a host path.
+func (w *Workspace) Rel(full string) string {
+	root, _ := filepath.Abs(w.root)
+	rel, err := filepath.Rel(root, full)
+	if err != nil {
+		return full
+	}
+	return filepath.ToSlash(rel)
+}
+
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+func fileMode(input map[string]interface{}) (string, error) {
+	mode, _ := input["mode"].(string)
+	switch mode {
+	case "":
+		return "text", nil
+	case "text", "binary", "json":
+		return mode, nil
+	default:
+		return "", InvalidInput("unsupported mode '%s' (expected text, binary or json)", mode)
+	}
+}
+
+func requiredPath(input map[string]interface{}) (string, error) {
+	pathVal, ok := input["path"]
+	if !ok {
+		return "", InvalidInput("missing required input 'path'")
+	}
+	p, ok := pathVal.(string)
+	if !ok || p == "" {
+		return "", InvalidInput("'path' must be a non-empty string")
+	}
+	return p, nil
+}
+
+// FileReadTool reads a file. Modes: text (default), binary (base64) and json (decoded).
+type FileReadTool struct {
+	ws *Workspace
+}
+
+func (t *FileReadTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	p, err := requiredPath(input)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := fileMode(input)
+	if err != nil {
+		return nil, err
+	}
+	full, err := t.ws.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case "binary":
+		return base64.StdEncoding.EncodeToString(data), nil
+	case "json":
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON in '%s': %w", p, err)
+		}
+		return v, nil
+	default:
+		return string(data), nil
+	}
+}
+
+// FileWriteTool writes 'content' to a file, creating parent directories.
+// Modes: text (default), binary (content is base64) and json (content is encoded).
+// Set 'append' to append instead of overwrite. Written files are recorded as artifacts.
+type FileWriteTool struct {
+	ws *Workspace
+}
+
+// Idempotent reports whether the write overwrites the file; appending twice is not safe.
+func (t *FileWriteTool) Idempotent(input map[string]interface{}) bool {
+	appendMode, _ := input["append"].(bool)
+	return !appendMode
+}
+
+func (t *FileWriteTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	p, err := requiredPath(input)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := fileMode(input)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := input["content"]
+	if !ok {
+		return nil, InvalidInput("missing required input 'content'")
+	}
+	full, err := t.ws.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch mode {
+	case "binary":
+		s, ok := content.(string)
+		if !ok {
+			return nil, InvalidInput("'content' must be a base64 string in binary mode")
+		}
+		if data, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+	case "json":
+		if data, err = json.MarshalIndent(content, "", "  "); err != nil {
+			return nil, err
+		}
+	default:
+		data = []byte(fmt.Sprintf("%v", content))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return nil, err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode, _ := input["append"].(bool); appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(full, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	rel := t.ws.Rel(full)
+	RecordArtifact(ctx, Artifact{Path: rel, Bytes: int64(len(data))})
+	return map[string]interface{}{
+		"path":  rel,
+		"bytes": len(data),
+	}, nil
+}
+
+// FileListTool lists the entries of a directory ('path', default the workspace root).
+type FileListTool struct {
+	ws *Workspace
+}
+
+func (t *FileListTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	p, _ := input["path"].(string)
+	if p == "" {
+		p = "."
+	}
+	full, err := t.ws.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"name": e.Name(),
+			"path": t.ws.Rel(filepath.Join(full, e.Name())),
+			"dir":  e.IsDir(),
+			"size": info.Size(),
+		})
+	}
+	return out, nil
+}
+
+// FileGlobTool returns workspace paths matching 'pattern'. Besides the usual
+// wildcards, a '**' segment matches any number of directories.
+type FileGlobTool struct {
+	ws *Workspace
+}
+
+func (t *FileGlobTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	patternVal, ok := input["pattern"]
+	if !ok {
+		return nil, InvalidInput("missing required input 'pattern'")
+	}
+	pattern, ok := patternVal.(string)
+	if !ok || pattern == "" {
+		return nil, InvalidInput("'pattern' must be a non-empty string")
+	}
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	if path.IsAbs(pattern) || pattern == ".." || strings.HasPrefix(pattern, "../") {
+		return nil, InvalidInput("pattern '%s' escapes the workspace", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	root, err := t.ws.Resolve(".")
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel := t.ws.Rel(p)
+		if globMatch(strings.Split(pattern, "/"), strings.Split(rel, "/")) {
+			matches = append(matches, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+	out := make([]interface{}, len(matches))
+	for i, m := range matches {
+		out[i] = m
+	}
+	return out, nil
+}
+
+// globMatch matches path segments against pattern segments, where '**'
+// matches zero or more segments.
+func globMatch(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if globMatch(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && globMatch(pattern[1:], segments[1:])
+}
+
+// FileTools returns file_read, file_write, file_list and file_glob confined
+// to the given workspace root.
+func FileTools(root string) map[string]Tool {
+	ws := NewWorkspace(root)
+	return map[string]Tool{
+		"file_read":  &FileReadTool{ws: ws},
+		"file_write": &FileWriteTool{ws: ws},
+		"file_list":  &FileListTool{ws: ws},
+		"file_glob":  &FileGlobTool{ws: ws},
+	}
+}
+
+// RegisterFileTools registers the file tools for root in the global registry.
+func RegisterFileTools(root string) {
+	for name, tool := range FileTools(root) {
+		Register(name, tool)
+	}
+}
+
+func init() {
+	RegisterFileTools(".")
+}
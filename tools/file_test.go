@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspaceResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "inside")); err != nil {
+		t.Fatal(err)
+	}
+	ws := NewWorkspace(root)
+
+	for _, p := range []string{".", "a.txt", "sub/a.txt", "sub/../a.txt", "new/dir/a.txt", "inside/a.txt"} {
+		full, err := ws.Resolve(p)
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", p, err)
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(p)); full != want {
+			t.Errorf("Resolve(%q) = %s, want %s", p, full, want)
+		}
+	}
+	for _, p := range []string{"..", "../x", "sub/../../x", "/etc/passwd", outside, "escape", "escape/a.txt", "escape/new/a.txt"} {
+		if _, err := ws.Resolve(p); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Resolve(%q) error = %v, want invalid input", p, err)
+		}
+	}
+}
+
+func runTool(t *testing.T, tool Tool, input map[string]interface{}) interface{} {
+	t.Helper()
+	out, err := tool.Run(context.Background(), input)
+	if err != nil {
+		t.Fatalf("%T(%v): %v", tool, input, err)
+	}
+	return out
+}
+
+func TestFileWriteAndReadModes(t *testing.T) {
+	ft := FileTools(t.TempDir())
+	write, read := ft["file_write"], ft["file_read"]
+
+	tests := []struct {
+		mode    string
+		content interface{}
+		want    interface{}
+	}{
+		{"", "hello", "hello"},
+		{"text", 42, "42"},
+		{"binary", "AAEC/w==", "AAEC/w=="},
+		{"json", map[string]interface{}{"a": []interface{}{1.0, "b"}}, map[string]interface{}{"a": []interface{}{1.0, "b"}}},
+	}
+	for _, tt := range tests {
+		path := "out/" + tt.mode + ".dat"
+		runTool(t, write, map[string]interface{}{"path": path, "mode": tt.mode, "content": tt.content})
+		if got := runTool(t, read, map[string]interface{}{"path": path, "mode": tt.mode}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mode %q: read %#v, want %#v", tt.mode, got, tt.want)
+		}
+	}
+
+	runTool(t, write, map[string]interface{}{"path": "log.txt", "content": "a"})
+	runTool(t, write, map[string]interface{}{"path": "log.txt", "content": "b", "append": true})
+	if got := runTool(t, read, map[string]interface{}{"path": "log.txt"}); got != "ab" {
+		t.Errorf("appended file = %q, want ab", got)
+	}
+	if IsIdempotent(write, map[string]interface{}{"append": true}) || !IsIdempotent(write, map[string]interface{}{}) {
+		t.Error("only appending writes should be non-idempotent")
+	}
+
+	for _, input := range []map[string]interface{}{
+		{"content": "x"},
+		{"path": "a.txt"},
+		{"path": "a.txt", "content": "x", "mode": "yaml"},
+		{"path": "a.txt", "content": 1, "mode": "binary"},
+		{"path": "../a.txt", "content": "x"},
+	} {
+		if _, err := write.Run(context.Background(), input); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("file_write(%v) error = %v, want invalid input", input, err)
+		}
+	}
+	if _, err := read.Run(context.Background(), map[string]interface{}{"path": "log.txt", "mode": "json"}); err == nil {
+		t.Error("reading text as JSON succeeded")
+	}
+}
+
+func TestFileWriteRecordsArtifacts(t *testing.T) {
+	write := FileTools(t.TempDir())["file_write"]
+	rec := &Recorder{}
+	ctx := WithRecorder(context.Background(), rec)
+	out, err := write.Run(ctx, map[string]interface{}{"path": "reports/a.txt", "content": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"path": "reports/a.txt", "bytes": 5}; !reflect.DeepEqual(out, want) {
+		t.Errorf("output = %v, want %v", out, want)
+	}
+	if got := rec.Artifacts(); !reflect.DeepEqual(got, []Artifact{{Path: "reports/a.txt", Bytes: 5}}) {
+		t.Errorf("artifacts = %+v", got)
+	}
+}
+
+func TestFileListAndGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"a.md", "docs/b.md", "docs/deep/c.md", "docs/deep/d.txt"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, p), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ft := FileTools(root)
+
+	list := runTool(t, ft["file_list"], map[string]interface{}{"path": "docs"}).([]interface{})
+	want := []interface{}{
+		map[string]interface{}{"name": "b.md", "path": "docs/b.md", "dir": false, "size": int64(1)},
+		map[string]interface{}{"name": "deep", "path": "docs/deep", "dir": true, "size": list[1].(map[string]interface{})["size"]},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("file_list = %v, want %v", list, want)
+	}
+
+	globs := []struct {
+		pattern string
+		want    []interface{}
+	}{
+		{"*.md", []interface{}{"a.md"}},
+		{"docs/*", []interface{}{"docs/b.md", "docs/deep"}},
+		{"**/*.md", []interface{}{"a.md", "docs/b.md", "docs/deep/c.md"}},
+		{"docs/**/*.txt", []interface{}{"docs/deep/d.txt"}},
+		{"*.go", []interface{}{}},
+	}
+	for _, g := range globs {
+		if got := runTool(t, ft["file_glob"], map[string]interface{}{"pattern": g.pattern}); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("file_glob(%q) = %v, want %v", g.pattern, got, g.want)
+		}
+	}
+	for _, pattern := range []string{"../*", "/etc/*", "docs/../../*"} {
+		if _, err := ft["file_glob"].Run(context.Background(), map[string]interface{}{"pattern": pattern}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("file_glob(%q) error = %v, want invalid input", pattern, err)
+		}
+	}
+}
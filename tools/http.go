@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -13,11 +14,11 @@ type HTTPGetTool struct{}
 func (t *HTTPGetTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
 	urlVal, ok := input["url"]
 	if !ok {
-		return nil, fmt.Errorf("missing required input 'url'")
+		return nil, InvalidInput("missing required input 'url'")
 	}
 	url, ok := urlVal.(string)
 	if !ok {
-		return nil, fmt.Errorf("'url' must be a string")
+		return nil, InvalidInput("'url' must be a string")
 	}
 
 	// Create a client with timeout
@@ -32,18 +33,45 @@ func (t *HTTPGetTool) Run(ctx context.Context, input map[string]interface{}) (in
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		// Network failures are usually transient
+		return nil, Retryable(err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, Retryable(err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, RateLimited(parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("GET %s: %s", url, resp.Status))
+	case resp.StatusCode >= 500:
+		return nil, Retryable(fmt.Errorf("GET %s: %s", url, resp.Status))
 	}
 
 	return string(body), nil
 }
 
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
 func init() {
 	Register("http_get", &HTTPGetTool{})
 }
@@ -11,14 +11,14 @@ type ParseJSONTool struct{}
 func (t *ParseJSONTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
 	sourceVal, ok := input["source"]
 	if !ok {
-		return nil, fmt.Errorf("missing required input 'source'")
+		return nil, InvalidInput("missing required input 'source'")
 	}
 	source, ok := sourceVal.(string)
 	if !ok {
-		return nil, fmt.Errorf("'source' must be a string")
+		return nil, InvalidInput("'source' must be a string")
 	}
 
-	var result map[string]interface{}
+	var result interface{}
 	if err := json.Unmarshal([]byte(source), &result); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
@@ -0,0 +1,395 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// mcpProtocolVersion is the MCP revision this client speaks.
+const mcpProtocolVersion = "2024-11-05"
+
+// MCPServerConfig describes an MCP server that is started as a child process
+// and spoken to over stdio.
+type MCPServerConfig struct {
+	Name    string   // Namespace used when registering tools: mcp.<name>.<tool>
+	Command string   // Executable to start
+	Args    []string // Command arguments
+	Env     []string // Extra environment variables in KEY=VALUE form
+	Dir     string   // Working directory (optional)
+
+	Stderr io.Writer // Receives the server's diagnostics; discarded when nil
+}
+
+// MCPToolInfo is a tool advertised by an MCP server via tools/list.
+type MCPToolInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+	Annotations *MCPToolAnnotations    `json:"annotations,omitempty"`
+}
+
+// MCPToolAnnotations are the behaviour hints a server may advertise for a tool.
+type MCPToolAnnotations struct {
+	ReadOnlyHint   *bool `json:"readOnlyHint,omitempty"`
+	IdempotentHint *bool `json:"idempotentHint,omitempty"`
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *int64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type rpcResponse struct {
+	ID     *int64          `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+// MCPClient is a JSON-RPC client for a single MCP server running over stdio.
+type MCPClient struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan rpcResponse
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+// StartMCPServer launches the server process and performs the initialize handshake.
+func StartMCPServer(ctx context.Context, cfg MCPServerConfig) (*MCPClient, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("mcp server is missing a name")
+	}
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("mcp server '%s' is missing a command", cfg.Name)
+	}
+
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	cmd.Stderr = cfg.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = io.Discard
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mcp server '%s': %w", cfg.Name, err)
+	}
+
+	c := &MCPClient{
+		name:    cfg.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan rpcResponse),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(stdout)
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mcp server '%s' handshake failed: %w", cfg.Name, err)
+	}
+	return c, nil
+}
+
+// Name returns the server namespace.
+func (c *MCPClient) Name() string {
+	return c.name
+}
+
+func (c *MCPClient) initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo": map[string]interface{}{
+			"name":    "floe",
+			"version": "0.5",
+		},
+	}
+	if _, err := c.call(ctx, "initialize", params); err != nil {
+		return err
+	}
+	return c.notify("notifications/initialized", nil)
+}
+
+// ListTools returns every tool the server exposes, following pagination cursors.
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPToolInfo, error) {
+	var all []MCPToolInfo
+	cursor := ""
+	for {
+		var params map[string]interface{}
+		if cursor != "" {
+			params = map[string]interface{}{"cursor": cursor}
+		}
+		raw, err := c.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tools      []MCPToolInfo `json:"tools"`
+			NextCursor string        `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("invalid tools/list result: %w", err)
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool invokes a server tool and converts its result into a Floe output value.
+// Structured content is returned as-is; otherwise text content blocks are joined.
+// The idempotency key in ctx, if any, is sent as _meta.idempotencyKey, and
+// usage reported by the server in _meta.usage is recorded.
+func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	params := map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	}
+	if key := IdempotencyKey(ctx); key != "" {
+		params["_meta"] = map[string]interface{}{"idempotencyKey": key}
+	}
+	raw, err := c.call(ctx, "tools/call", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StructuredContent interface{} `json:"structuredContent"`
+		IsError           bool        `json:"isError"`
+		Meta              struct {
+			Usage *struct {
+				Model        string  `json:"model"`
+				InputTokens  int64   `json:"inputTokens"`
+				OutputTokens int64   `json:"outputTokens"`
+				Cost         float64 `json:"cost"`
+			} `json:"usage"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("invalid tools/call result: %w", err)
+	}
+	if u := res.Meta.Usage; u != nil {
+		RecordUsage(ctx, Usage{Model: u.Model, InputTokens: u.InputTokens, OutputTokens: u.OutputTokens, Cost: u.Cost})
+	}
+
+	var texts []string
+	for _, block := range res.Content {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	text := strings.Join(texts, "\n")
+
+	if res.IsError {
+		return nil, fmt.Errorf("mcp tool '%s' failed: %s", name, text)
+	}
+	if res.StructuredContent != nil {
+		return res.StructuredContent, nil
+	}
+	return text, nil
+}
+
+// Close stops the server process.
+func (c *MCPClient) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	c.stdin.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	<-c.done
+	_ = c.cmd.Wait()
+	return nil
+}
+
+func (c *MCPClient) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.closed || c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		if err == nil {
+			err = fmt.Errorf("mcp server '%s' is closed", c.name)
+		}
+		return nil, err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan rpcResponse, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.send(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		c.forget(id)
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("mcp server '%s' exited: %v", c.name, c.exitErr())
+		}
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		c.forget(id)
+		_ = c.notify("notifications/cancelled", map[string]interface{}{
+			"requestId": id,
+			"reason":    ctx.Err().Error(),
+		})
+		return nil, ctx.Err()
+	}
+}
+
+func (c *MCPClient) notify(method string, params interface{}) error {
+	return c.send(rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (c *MCPClient) send(req rpcRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (c *MCPClient) forget(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *MCPClient) exitErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *MCPClient) readLoop(r io.Reader) {
+	defer close(c.done)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var resp rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue // Ignore non-JSON noise on stdout
+		}
+		// Server-initiated requests and notifications are not supported
+		if resp.ID == nil || resp.Method != "" {
+			continue
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[*resp.ID]
+		delete(c.pending, *resp.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+
+	c.mu.Lock()
+	c.err = scanner.Err()
+	if c.err == nil {
+		c.err = io.EOF
+	}
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// MCPTool proxies a single MCP server tool through the Tool interface.
+type MCPTool struct {
+	client *MCPClient
+	name   string
+	Info   MCPToolInfo
+}
+
+func (t *MCPTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	return t.client.CallTool(ctx, t.name, input)
+}
+
+// Idempotent follows the server's annotations. As in the MCP spec, a tool is
+// only idempotent when it is marked read-only or idempotent; tools without
+// annotations are not.
+func (t *MCPTool) Idempotent(input map[string]interface{}) bool {
+	a := t.Info.Annotations
+	if a == nil {
+		return false
+	}
+	if a.ReadOnlyHint != nil && *a.ReadOnlyHint {
+		return true
+	}
+	return a.IdempotentHint != nil && *a.IdempotentHint
+}
+
+// Tools lists the server's tools and wraps each one as a Tool named
+// mcp.<server>.<tool>. The tools are not added to the global Registry, so
+// runs using servers with the same name do not see each other's tools.
+func (c *MCPClient) Tools(ctx context.Context) (map[string]Tool, error) {
+	infos, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools of mcp server '%s': %w", c.name, err)
+	}
+	out := make(map[string]Tool, len(infos))
+	for _, info := range infos {
+		out["mcp."+c.name+"."+info.Name] = &MCPTool{client: c, name: info.Name, Info: info}
+	}
+	return out, nil
+}
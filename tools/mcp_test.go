@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubServerEnv makes the test binary act as an MCP server over stdio.
+const stubServerEnv = "FLOE_MCP_STUB_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubServerEnv) == "1" {
+		runStubMCPServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runStubMCPServer answers initialize, tools/list (in two pages) and
+// tools/call for an "echo" and a "fail" tool.
+func runStubMCPServer() {
+	fmt.Fprintln(os.Stderr, "stub server ready")
+	in := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	initialized := false
+	for in.Scan() {
+		var req struct {
+			ID     *int64                 `json:"id"`
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil {
+			continue
+		}
+		if req.ID == nil {
+			if req.Method == "notifications/initialized" {
+				initialized = true
+			}
+			continue
+		}
+		reply := func(result interface{}) {
+			_ = out.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": *req.ID, "result": result})
+		}
+		switch req.Method {
+		case "initialize":
+			reply(map[string]interface{}{
+				"protocolVersion": req.Params["protocolVersion"],
+				"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+				"serverInfo":      map[string]interface{}{"name": "stub", "version": "1"},
+			})
+		case "tools/list":
+			if !initialized {
+				_ = out.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": *req.ID,
+					"error": map[string]interface{}{"code": -32002, "message": "not initialized"}})
+				continue
+			}
+			if req.Params["cursor"] == nil {
+				reply(map[string]interface{}{
+					"tools":      []interface{}{map[string]interface{}{"name": "echo", "annotations": map[string]interface{}{"readOnlyHint": true}}},
+					"nextCursor": "page2",
+				})
+			} else {
+				reply(map[string]interface{}{
+					"tools": []interface{}{map[string]interface{}{"name": "fail"}},
+				})
+			}
+		case "tools/call":
+			args, _ := req.Params["arguments"].(map[string]interface{})
+			switch req.Params["name"] {
+			case "echo":
+				reply(map[string]interface{}{
+					"content": []interface{}{
+						map[string]interface{}{"type": "text", "text": fmt.Sprint(args["text"])},
+						map[string]interface{}{"type": "text", "text": "done"},
+					},
+					"_meta": map[string]interface{}{
+						"usage": map[string]interface{}{"model": "stub-model", "inputTokens": 3, "outputTokens": 4, "cost": 0.5},
+					},
+				})
+			default:
+				reply(map[string]interface{}{
+					"content": []interface{}{map[string]interface{}{"type": "text", "text": "boom"}},
+					"isError": true,
+				})
+			}
+		default:
+			_ = out.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": *req.ID,
+				"error": map[string]interface{}{"code": -32601, "message": "method not found"}})
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the exec stderr copier.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func startStubServer(t *testing.T, name string, stderr *syncBuffer) *MCPClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg := MCPServerConfig{
+		Name:    name,
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^$"},
+		Env:     []string{stubServerEnv + "=1"},
+	}
+	if stderr != nil {
+		cfg.Stderr = stderr
+	}
+	client, err := StartMCPServer(ctx, cfg)
+	if err != nil {
+		t.Fatalf("StartMCPServer: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestMCPClientListsAndCallsTools(t *testing.T) {
+	stderr := &syncBuffer{}
+	client := startStubServer(t, "stub", stderr)
+	ctx := context.Background()
+
+	tools, err := client.Tools(ctx)
+	if err != nil {
+		t.Fatalf("Tools: %v", err)
+	}
+	if len(tools) != 2 || tools["mcp.stub.echo"] == nil || tools["mcp.stub.fail"] == nil {
+		t.Fatalf("unexpected tools: %v", tools)
+	}
+	if _, err := Get("mcp.stub.echo"); err == nil {
+		t.Error("MCP tools must not be added to the global registry")
+	}
+
+	rec := &Recorder{}
+	out, err := tools["mcp.stub.echo"].Run(WithRecorder(ctx, rec), map[string]interface{}{"text": "hello"})
+	if err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+	if out != "hello\ndone" {
+		t.Errorf("echo output = %q, want %q", out, "hello\ndone")
+	}
+	u := rec.Usage()
+	if u == nil || u.Model != "stub-model" || u.TotalTokens() != 7 || u.Cost != 0.5 {
+		t.Errorf("recorded usage = %+v", u)
+	}
+
+	if _, err := tools["mcp.stub.fail"].Run(ctx, nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("fail error = %v, want it to mention boom", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(stderr.String(), "stub server ready") {
+		if time.Now().After(deadline) {
+			t.Fatalf("server stderr was not forwarded, got %q", stderr.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMCPToolIdempotencyFollowsAnnotations(t *testing.T) {
+	client := startStubServer(t, "stub", nil)
+	tools, err := client.Tools(context.Background())
+	if err != nil {
+		t.Fatalf("Tools: %v", err)
+	}
+	if !IsIdempotent(tools["mcp.stub.echo"], nil) {
+		t.Error("read-only tool should be idempotent")
+	}
+	if IsIdempotent(tools["mcp.stub.fail"], nil) {
+		t.Error("tool without annotations should not be idempotent")
+	}
+}
+
+func TestMCPClientReportsClosedServer(t *testing.T) {
+	client := startStubServer(t, "stub", nil)
+	_ = client.Close()
+	if _, err := client.CallTool(context.Background(), "echo", nil); err == nil {
+		t.Error("call on a closed server should fail")
+	}
+}
+
+func TestStartMCPServerValidatesConfig(t *testing.T) {
+	ctx := context.Background()
+	if _, err := StartMCPServer(ctx, MCPServerConfig{Command: "x"}); err == nil {
+		t.Error("missing name should be rejected")
+	}
+	if _, err := StartMCPServer(ctx, MCPServerConfig{Name: "x"}); err == nil {
+		t.Error("missing command should be rejected")
+	}
+}
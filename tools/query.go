@@ -0,0 +1,1156 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements the jq subset used by the transform tool.
+//
+// Supported syntax:
+//   .  .foo  ."key"  .[0]  .[-1]  .[1:3]  .[]  .foo[]  a | b  a, b
+//   [ ... ]  { key: expr, "k": expr, (expr): expr, key }
+//   == != < <= > >=  and  or  + -
+//   literals: "str" 1 2.5 true false null
+//   functions: length keys first last not sort unique tostring tonumber
+//              map(f) select(f) sort_by(f) join(sep) has(key)
+
+// filter maps one input value to a stream of output values.
+type filter func(v interface{}) ([]interface{}, error)
+
+// compileQuery parses a query string into a filter.
+func compileQuery(query string) (filter, error) {
+	toks, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{toks: toks}
+	f, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if !p.at(tokEOF) {
+		return nil, fmt.Errorf("unexpected token '%s'", p.peek().val)
+	}
+	return f, nil
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokPunct
+	tokIdent
+	tokString
+	tokNumber
+)
+
+type token struct {
+	kind tokKind
+	val  string
+}
+
+func lexQuery(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string in query")
+			}
+			str, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in query", s[i:j+1])
+			}
+			toks = append(toks, token{tokString, str})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j]})
+			i = j
+		default:
+			if i+1 < len(s) {
+				two := s[i : i+2]
+				if two == "==" || two == "!=" || two == "<=" || two == ">=" {
+					toks = append(toks, token{tokPunct, two})
+					i += 2
+					continue
+				}
+			}
+			if strings.ContainsRune(".[]{}()|,:<>+-", rune(c)) {
+				toks = append(toks, token{tokPunct, string(c)})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character '%c' in query", c)
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+// --- parser ---
+
+type queryParser struct {
+	toks []token
+	pos  int
+}
+
+func (p *queryParser) peek() token { return p.toks[p.pos] }
+
+func (p *queryParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) at(kind tokKind) bool { return p.peek().kind == kind }
+
+func (p *queryParser) atPunct(val string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.val == val
+}
+
+func (p *queryParser) atIdent(val string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.val == val
+}
+
+func (p *queryParser) expect(val string) error {
+	if !p.atPunct(val) {
+		return fmt.Errorf("expected '%s', got '%s'", val, p.peek().val)
+	}
+	p.next()
+	return nil
+}
+
+func (p *queryParser) parsePipe() (filter, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.atPunct("|") {
+		p.next()
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeFilter(left, right)
+	}
+	return left, nil
+}
+
+// parseObjectValue parses an object value: a pipeline that stops at ',' so
+// the comma can separate entries, as in jq's {a: .x | length, b: .y}.
+func (p *queryParser) parseObjectValue() (filter, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.atPunct("|") {
+		p.next()
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeFilter(left, right)
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseComma() (filter, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.atPunct(",") {
+		p.next()
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = func(v interface{}) ([]interface{}, error) {
+			a, err := l(v)
+			if err != nil {
+				return nil, err
+			}
+			b, err := r(v)
+			if err != nil {
+				return nil, err
+			}
+			return append(a, b...), nil
+		}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.atIdent("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryFilter(left, right, func(a, b interface{}) (interface{}, error) {
+			return truthy(a) || truthy(b), nil
+		})
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (filter, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.atIdent("and") {
+		p.next()
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryFilter(left, right, func(a, b interface{}) (interface{}, error) {
+			return truthy(a) && truthy(b), nil
+		})
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseCompare() (filter, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.atPunct(op) {
+			p.next()
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			operator := op
+			return binaryFilter(left, right, func(a, b interface{}) (interface{}, error) {
+				c := compareValues(a, b)
+				switch operator {
+				case "==":
+					return c == 0, nil
+				case "!=":
+					return c != 0, nil
+				case "<":
+					return c < 0, nil
+				case "<=":
+					return c <= 0, nil
+				case ">":
+					return c > 0, nil
+				default:
+					return c >= 0, nil
+				}
+			}), nil
+		}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAdditive() (filter, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for p.atPunct("+") || p.atPunct("-") {
+		op := p.next().val
+		right, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			left = binaryFilter(left, right, addValues)
+		} else {
+			left = binaryFilter(left, right, subValues)
+		}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parsePostfix() (filter, error) {
+	f, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.atPunct("."):
+			p.next()
+			t := p.next()
+			if t.kind != tokIdent && t.kind != tokString {
+				return nil, fmt.Errorf("expected field name after '.', got '%s'", t.val)
+			}
+			f = pipeFilter(f, fieldFilter(t.val))
+		case p.atPunct("["):
+			suffix, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			f = pipeFilter(f, suffix)
+		default:
+			return f, nil
+		}
+	}
+}
+
+// parseBracket parses [], [expr] and [from:to] suffixes.
+func (p *queryParser) parseBracket() (filter, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	if p.atPunct("]") {
+		p.next()
+		return iterateFilter, nil
+	}
+
+	var from, to filter
+	var err error
+	if !p.atPunct(":") {
+		if from, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if p.atPunct(":") {
+		p.next()
+		if !p.atPunct("]") {
+			if to, err = p.parsePipe(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return sliceFilter(from, to), nil
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return indexFilter(from), nil
+}
+
+func (p *queryParser) parsePrimary() (filter, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokString:
+		p.next()
+		return constFilter(t.val), nil
+	case tokNumber:
+		p.next()
+		n, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", t.val)
+		}
+		return constFilter(n), nil
+	case tokIdent:
+		return p.parseFunction()
+	case tokPunct:
+		switch t.val {
+		case ".":
+			p.next()
+			if n := p.peek(); n.kind == tokIdent || n.kind == tokString {
+				p.next()
+				return fieldFilter(n.val), nil
+			}
+			return identityFilter, nil
+		case "-":
+			p.next()
+			operand, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return binaryFilter(constFilter(0.0), operand, subValues), nil
+		case "(":
+			p.next()
+			f, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			return f, p.expect(")")
+		case "[":
+			p.next()
+			if p.atPunct("]") {
+				p.next()
+				return constFilter([]interface{}{}), nil
+			}
+			f, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return func(v interface{}) ([]interface{}, error) {
+				out, err := f(v)
+				if err != nil {
+					return nil, err
+				}
+				if out == nil {
+					out = []interface{}{}
+				}
+				return []interface{}{out}, nil
+			}, nil
+		case "{":
+			return p.parseObject()
+		}
+	}
+	return nil, fmt.Errorf("unexpected token '%s'", t.val)
+}
+
+type objectEntry struct {
+	key   filter
+	value filter
+}
+
+func (p *queryParser) parseObject() (filter, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var entries []objectEntry
+	for !p.atPunct("}") {
+		var key filter
+		var shorthand string
+		t := p.next()
+		switch {
+		case t.kind == tokIdent || t.kind == tokString:
+			key = constFilter(t.val)
+			shorthand = t.val
+		case t.kind == tokPunct && t.val == "(":
+			k, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			key = k
+		default:
+			return nil, fmt.Errorf("invalid object key '%s'", t.val)
+		}
+
+		var value filter
+		if p.atPunct(":") {
+			p.next()
+			v, err := p.parseObjectValue()
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		} else if shorthand != "" {
+			value = fieldFilter(shorthand)
+		} else {
+			return nil, fmt.Errorf("object key expression must be followed by ':'")
+		}
+		entries = append(entries, objectEntry{key: key, value: value})
+
+		if !p.atPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+
+	return func(v interface{}) ([]interface{}, error) {
+		results := []map[string]interface{}{{}}
+		for _, e := range entries {
+			keys, err := e.key(v)
+			if err != nil {
+				return nil, err
+			}
+			values, err := e.value(v)
+			if err != nil {
+				return nil, err
+			}
+			var next []map[string]interface{}
+			for _, obj := range results {
+				for _, k := range keys {
+					ks, ok := k.(string)
+					if !ok {
+						return nil, fmt.Errorf("object keys must be strings, got %T", k)
+					}
+					for _, val := range values {
+						cp := make(map[string]interface{}, len(obj)+1)
+						for ok, ov := range obj {
+							cp[ok] = ov
+						}
+						cp[ks] = val
+						next = append(next, cp)
+					}
+				}
+			}
+			results = next
+		}
+		out := make([]interface{}, len(results))
+		for i, r := range results {
+			out[i] = r
+		}
+		return out, nil
+	}, nil
+}
+
+func (p *queryParser) parseFunction() (filter, error) {
+	name := p.next().val
+	switch name {
+	case "true":
+		return constFilter(true), nil
+	case "false":
+		return constFilter(false), nil
+	case "null":
+		return constFilter(nil), nil
+	}
+
+	var args []filter
+	if p.atPunct("(") {
+		p.next()
+		arg, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	builtin, ok := queryBuiltins[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s'", name)
+	}
+	if len(args) != builtin.arity {
+		return nil, fmt.Errorf("function '%s' takes %d argument(s), got %d", name, builtin.arity, len(args))
+	}
+	return builtin.build(args), nil
+}
+
+// --- filters ---
+
+func identityFilter(v interface{}) ([]interface{}, error) {
+	return []interface{}{v}, nil
+}
+
+func constFilter(c interface{}) filter {
+	return func(interface{}) ([]interface{}, error) {
+		return []interface{}{c}, nil
+	}
+}
+
+func pipeFilter(left, right filter) filter {
+	return func(v interface{}) ([]interface{}, error) {
+		in, err := left(v)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, item := range in {
+			res, err := right(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, res...)
+		}
+		return out, nil
+	}
+}
+
+func binaryFilter(left, right filter, op func(a, b interface{}) (interface{}, error)) filter {
+	return func(v interface{}) ([]interface{}, error) {
+		ls, err := left(v)
+		if err != nil {
+			return nil, err
+		}
+		rs, err := right(v)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, r := range rs {
+			for _, l := range ls {
+				res, err := op(l, r)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, res)
+			}
+		}
+		return out, nil
+	}
+}
+
+func fieldFilter(name string) filter {
+	return func(v interface{}) ([]interface{}, error) {
+		switch obj := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case map[string]interface{}:
+			return []interface{}{obj[name]}, nil
+		default:
+			return nil, fmt.Errorf("cannot index %s with \"%s\"", typeName(v), name)
+		}
+	}
+}
+
+func iterateFilter(v interface{}) ([]interface{}, error) {
+	switch c := v.(type) {
+	case []interface{}:
+		return append([]interface{}{}, c...), nil
+	case map[string]interface{}:
+		keys := sortedKeys(c)
+		out := make([]interface{}, len(keys))
+		for i, k := range keys {
+			out[i] = c[k]
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("cannot iterate over %s", typeName(v))
+	}
+}
+
+func indexFilter(index filter) filter {
+	return func(v interface{}) ([]interface{}, error) {
+		idxs, err := index(v)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, idx := range idxs {
+			switch i := idx.(type) {
+			case string:
+				res, err := fieldFilter(i)(v)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, res...)
+			case float64:
+				if v == nil {
+					out = append(out, nil)
+					continue
+				}
+				arr, ok := v.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("cannot index %s with number", typeName(v))
+				}
+				n := int(i)
+				if n < 0 {
+					n += len(arr)
+				}
+				if n < 0 || n >= len(arr) {
+					out = append(out, nil)
+				} else {
+					out = append(out, arr[n])
+				}
+			default:
+				return nil, fmt.Errorf("cannot index with %s", typeName(idx))
+			}
+		}
+		return out, nil
+	}
+}
+
+func sliceFilter(from, to filter) filter {
+	bound := func(f filter, v interface{}, def int) (int, error) {
+		if f == nil {
+			return def, nil
+		}
+		res, err := f(v)
+		if err != nil {
+			return 0, err
+		}
+		if len(res) != 1 {
+			return 0, fmt.Errorf("slice bounds must produce a single value")
+		}
+		n, ok := res[0].(float64)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers")
+		}
+		return int(n), nil
+	}
+	return func(v interface{}) ([]interface{}, error) {
+		var length int
+		switch c := v.(type) {
+		case []interface{}:
+			length = len(c)
+		case string:
+			length = len([]rune(c))
+		case nil:
+			return []interface{}{nil}, nil
+		default:
+			return nil, fmt.Errorf("cannot slice %s", typeName(v))
+		}
+		start, err := bound(from, v, 0)
+		if err != nil {
+			return nil, err
+		}
+		end, err := bound(to, v, length)
+		if err != nil {
+			return nil, err
+		}
+		start, end = clampIndex(start, length), clampIndex(end, length)
+		if end < start {
+			end = start
+		}
+		if s, ok := v.(string); ok {
+			return []interface{}{string([]rune(s)[start:end])}, nil
+		}
+		return []interface{}{append([]interface{}{}, v.([]interface{})[start:end]...)}, nil
+	}
+}
+
+func clampIndex(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+// --- builtins ---
+
+type queryBuiltin struct {
+	arity int
+	build func(args []filter) filter
+}
+
+func simpleBuiltin(fn func(v interface{}) (interface{}, error)) queryBuiltin {
+	return queryBuiltin{arity: 0, build: func([]filter) filter {
+		return func(v interface{}) ([]interface{}, error) {
+			res, err := fn(v)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{res}, nil
+		}
+	}}
+}
+
+var queryBuiltins map[string]queryBuiltin
+
+func init() {
+	queryBuiltins = map[string]queryBuiltin{
+		"length": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			switch c := v.(type) {
+			case nil:
+				return 0.0, nil
+			case string:
+				return float64(len([]rune(c))), nil
+			case []interface{}:
+				return float64(len(c)), nil
+			case map[string]interface{}:
+				return float64(len(c)), nil
+			case float64:
+				if c < 0 {
+					return -c, nil
+				}
+				return c, nil
+			default:
+				return nil, fmt.Errorf("%s has no length", typeName(v))
+			}
+		}),
+		"keys": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			switch c := v.(type) {
+			case map[string]interface{}:
+				keys := sortedKeys(c)
+				out := make([]interface{}, len(keys))
+				for i, k := range keys {
+					out[i] = k
+				}
+				return out, nil
+			case []interface{}:
+				out := make([]interface{}, len(c))
+				for i := range c {
+					out[i] = float64(i)
+				}
+				return out, nil
+			default:
+				return nil, fmt.Errorf("%s has no keys", typeName(v))
+			}
+		}),
+		"first": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			arr, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("first requires an array, got %s", typeName(v))
+			}
+			if len(arr) == 0 {
+				return nil, nil
+			}
+			return arr[0], nil
+		}),
+		"last": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			arr, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("last requires an array, got %s", typeName(v))
+			}
+			if len(arr) == 0 {
+				return nil, nil
+			}
+			return arr[len(arr)-1], nil
+		}),
+		"not": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			return !truthy(v), nil
+		}),
+		"sort": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			arr, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("sort requires an array, got %s", typeName(v))
+			}
+			out := append([]interface{}{}, arr...)
+			sort.SliceStable(out, func(i, j int) bool { return compareValues(out[i], out[j]) < 0 })
+			return out, nil
+		}),
+		"unique": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			arr, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unique requires an array, got %s", typeName(v))
+			}
+			sorted := append([]interface{}{}, arr...)
+			sort.SliceStable(sorted, func(i, j int) bool { return compareValues(sorted[i], sorted[j]) < 0 })
+			out := []interface{}{}
+			for i, item := range sorted {
+				if i == 0 || compareValues(item, sorted[i-1]) != 0 {
+					out = append(out, item)
+				}
+			}
+			return out, nil
+		}),
+		"tostring": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			return string(data), nil
+		}),
+		"tonumber": simpleBuiltin(func(v interface{}) (interface{}, error) {
+			switch c := v.(type) {
+			case float64:
+				return c, nil
+			case string:
+				n, err := strconv.ParseFloat(strings.TrimSpace(c), 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse '%s' as number", c)
+				}
+				return n, nil
+			default:
+				return nil, fmt.Errorf("cannot convert %s to number", typeName(v))
+			}
+		}),
+		"map": {arity: 1, build: func(args []filter) filter {
+			return pipeFilter(iterateFilter, args[0]).collect()
+		}},
+		"select": {arity: 1, build: func(args []filter) filter {
+			cond := args[0]
+			return func(v interface{}) ([]interface{}, error) {
+				res, err := cond(v)
+				if err != nil {
+					return nil, err
+				}
+				var out []interface{}
+				for _, r := range res {
+					if truthy(r) {
+						out = append(out, v)
+					}
+				}
+				return out, nil
+			}
+		}},
+		"sort_by": {arity: 1, build: func(args []filter) filter {
+			key := args[0]
+			return func(v interface{}) ([]interface{}, error) {
+				arr, ok := v.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("sort_by requires an array, got %s", typeName(v))
+				}
+				keys := make([]interface{}, len(arr))
+				for i, item := range arr {
+					res, err := key(item)
+					if err != nil {
+						return nil, err
+					}
+					keys[i] = res
+				}
+				idx := make([]int, len(arr))
+				for i := range idx {
+					idx[i] = i
+				}
+				sort.SliceStable(idx, func(a, b int) bool {
+					return compareValues(keys[idx[a]], keys[idx[b]]) < 0
+				})
+				out := make([]interface{}, len(arr))
+				for i, j := range idx {
+					out[i] = arr[j]
+				}
+				return []interface{}{out}, nil
+			}
+		}},
+		"join": {arity: 1, build: func(args []filter) filter {
+			sepFilter := args[0]
+			return func(v interface{}) ([]interface{}, error) {
+				arr, ok := v.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("join requires an array, got %s", typeName(v))
+				}
+				seps, err := sepFilter(v)
+				if err != nil {
+					return nil, err
+				}
+				var out []interface{}
+				for _, s := range seps {
+					sep, ok := s.(string)
+					if !ok {
+						return nil, fmt.Errorf("join separator must be a string")
+					}
+					parts := make([]string, len(arr))
+					for i, item := range arr {
+						if item == nil {
+							continue
+						}
+						if str, ok := item.(string); ok {
+							parts[i] = str
+						} else {
+							parts[i] = formatNumberOrJSON(item)
+						}
+					}
+					out = append(out, strings.Join(parts, sep))
+				}
+				return out, nil
+			}
+		}},
+		"has": {arity: 1, build: func(args []filter) filter {
+			keyFilter := args[0]
+			return func(v interface{}) ([]interface{}, error) {
+				keys, err := keyFilter(v)
+				if err != nil {
+					return nil, err
+				}
+				var out []interface{}
+				for _, k := range keys {
+					switch c := v.(type) {
+					case map[string]interface{}:
+						ks, ok := k.(string)
+						if !ok {
+							return nil, fmt.Errorf("has: object keys must be strings")
+						}
+						_, exists := c[ks]
+						out = append(out, exists)
+					case []interface{}:
+						n, ok := k.(float64)
+						if !ok {
+							return nil, fmt.Errorf("has: array indices must be numbers")
+						}
+						out = append(out, n >= 0 && int(n) < len(c))
+					default:
+						return nil, fmt.Errorf("cannot check keys of %s", typeName(v))
+					}
+				}
+				return out, nil
+			}
+		}},
+	}
+}
+
+// collect wraps all outputs of f into a single array.
+func (f filter) collect() filter {
+	return func(v interface{}) ([]interface{}, error) {
+		out, err := f(v)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = []interface{}{}
+		}
+		return []interface{}{out}, nil
+	}
+}
+
+// --- value helpers ---
+
+func truthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// typeOrder follows jq's ordering: null < false < true < numbers < strings < arrays < objects.
+func typeOrder(v interface{}) int {
+	switch c := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if c {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	default:
+		return 6
+	}
+}
+
+func compareValues(a, b interface{}) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		if ta < tb {
+			return -1
+		}
+		return 1
+	}
+	switch x := a.(type) {
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	case []interface{}:
+		y := b.([]interface{})
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareValues(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return len(x) - len(y)
+	case map[string]interface{}:
+		ja, _ := json.Marshal(x)
+		jb, _ := json.Marshal(b)
+		return strings.Compare(string(ja), string(jb))
+	}
+	return 0
+}
+
+func addValues(a, b interface{}) (interface{}, error) {
+	if a == nil {
+		return b, nil
+	}
+	if b == nil {
+		return a, nil
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return x + y, nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return x + y, nil
+		}
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok {
+			return append(append([]interface{}{}, x...), y...), nil
+		}
+	case map[string]interface{}:
+		if y, ok := b.(map[string]interface{}); ok {
+			out := make(map[string]interface{}, len(x)+len(y))
+			for k, v := range x {
+				out[k] = v
+			}
+			for k, v := range y {
+				out[k] = v
+			}
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot add %s and %s", typeName(a), typeName(b))
+}
+
+func subValues(a, b interface{}) (interface{}, error) {
+	x, ok1 := a.(float64)
+	y, ok2 := b.(float64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("cannot subtract %s from %s", typeName(b), typeName(a))
+	}
+	return x - y, nil
+}
+
+func formatNumberOrJSON(v interface{}) string {
+	if n, ok := v.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const queryTestDoc = `{
+	"name": "floe",
+	"tags": ["b", "a", "b"],
+	"n": 3,
+	"items": [
+		{"id": 1, "kind": "x", "score": 0.5},
+		{"id": 2, "kind": "y", "score": 2},
+		{"id": 3, "kind": "x", "score": 1}
+	],
+	"nested": {"a": {"b": "deep"}},
+	"empty": []
+}`
+
+func TestCompileQuery(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(queryTestDoc), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  string // JSON array of all results
+	}{
+		{`.`, `[` + queryTestDoc + `]`},
+		{`.name`, `["floe"]`},
+		{`.missing`, `[null]`},
+		{`.nested.a.b`, `["deep"]`},
+		{`."name"`, `["floe"]`},
+		{`.tags[0]`, `["b"]`},
+		{`.tags[-1]`, `["b"]`},
+		{`.tags[1:]`, `[["a","b"]]`},
+		{`.tags[:2]`, `[["b","a"]]`},
+		{`.tags[]`, `["b","a","b"]`},
+		{`.items[].id`, `[1,2,3]`},
+		{`.empty[]`, `[]`},
+		{`.name, .n`, `["floe",3]`},
+		{`.items | length`, `[3]`},
+		{`[.items[] | .id]`, `[[1,2,3]]`},
+		{`.items | map(.kind)`, `[["x","y","x"]]`},
+		{`.items[] | select(.kind == "x") | .id`, `[1,3]`},
+		{`.items | sort_by(.score) | map(.id)`, `[[1,3,2]]`},
+		{`.items | map(.score) | first`, `[0.5]`},
+		{`.items | last | .id`, `[3]`},
+		{`.tags | sort`, `[["a","b","b"]]`},
+		{`.tags | unique`, `[["a","b"]]`},
+		{`.tags | join("-")`, `["b-a-b"]`},
+		{`.nested | keys`, `[["a"]]`},
+		{`. | has("name")`, `[true]`},
+		{`.n + 1`, `[4]`},
+		{`.n - 1`, `[2]`},
+		{`.name + "!"`, `["floe!"]`},
+		{`.n > 2 and .n < 4`, `[true]`},
+		{`.n == 1 or .n != 1`, `[true]`},
+		{`.n <= 3`, `[true]`},
+		{`.n >= 4`, `[false]`},
+		{`.n | not`, `[false]`},
+		{`.n | tostring`, `["3"]`},
+		{`"42" | tonumber`, `[42]`},
+		{`null`, `[null]`},
+		{`true, false`, `[true,false]`},
+		{`{name}`, `[{"name":"floe"}]`},
+		{`{"k": .n, name}`, `[{"k":3,"name":"floe"}]`},
+		{`{(.name): 1}`, `[{"floe":1}]`},
+		{`{count: .items | length, first: .items[0].id}`, `[{"count":3,"first":1}]`},
+		{`{id: .items[].id}`, `[{"id":1},{"id":2},{"id":3}]`},
+		{`[.items[] | {id, kind}] | length`, `[3]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			f, err := compileQuery(tt.query)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			got, err := f(doc)
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if got == nil {
+				got = []interface{}{}
+			}
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("bad expectation %s: %v", tt.want, err)
+			}
+			if !reflect.DeepEqual(interface{}(got), want) {
+				gotJSON, _ := json.Marshal(got)
+				t.Errorf("got %s, want %s", gotJSON, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`.[`,
+		`{a: }`,
+		`{(.a) }`,
+		`unknownfn`,
+		`map`,
+		`.a |`,
+		`"unterminated`,
+	}
+	for _, query := range tests {
+		t.Run(query, func(t *testing.T) {
+			if _, err := compileQuery(query); err == nil {
+				t.Errorf("expected a parse error for %q", query)
+			}
+		})
+	}
+}
+
+func TestQueryRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		input string
+	}{
+		{`.a`, `[1]`},
+		{`.[0]`, `{"a":1}`},
+		{`.[]`, `5`},
+		{`length`, `true`},
+		{`{(.): 1}`, `5`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var input interface{}
+			if err := json.Unmarshal([]byte(tt.input), &input); err != nil {
+				t.Fatal(err)
+			}
+			f, err := compileQuery(tt.query)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if _, err := f(input); err == nil {
+				t.Errorf("expected an error running %q on %s", tt.query, tt.input)
+			}
+		})
+	}
+}
+
+func TestTransformToolResults(t *testing.T) {
+	tool := &TransformTool{}
+	tests := []struct {
+		query string
+		want  interface{}
+	}{
+		{`.a`, 1.0},
+		{`.b[]`, []interface{}{1.0, 2.0}},
+		{`.b[] | select(. > 5)`, []interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			out, err := tool.Run(context.Background(), map[string]interface{}{
+				"query":  tt.query,
+				"source": `{"a": 1, "b": [1, 2]}`,
+			})
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if !reflect.DeepEqual(out, tt.want) {
+				t.Errorf("got %#v, want %#v", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformToolReadsMemoryPath(t *testing.T) {
+	ctx := WithMemory(context.Background(), map[string]interface{}{
+		"global": map[string]interface{}{"resp": `{"items": [{"id": 7}]}`},
+	})
+	out, err := (&TransformTool{}).Run(ctx, map[string]interface{}{
+		"query": `.items[0].id`,
+		"path":  "global.resp",
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if out != 7.0 {
+		t.Errorf("got %#v, want 7", out)
+	}
+}
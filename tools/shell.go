@@ -0,0 +1,238 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// defaultShellMaxOutput caps captured stdout and stderr (each) when no limit is configured.
+const defaultShellMaxOutput = 1 << 20
+
+// shellWaitDelay bounds how long a cancelled command may keep its output
+// pipes open, e.g. through a background grandchild, before Run gives up.
+const shellWaitDelay = 2 * time.Second
+
+// ShellConfig controls the shell tool. The tool is unsafe: it runs host
+// commands, so it refuses to run unless Enabled is set explicitly.
+type ShellConfig struct {
+	Enabled        bool     // Must be true for the tool to run anything
+	Allow          []string // Allowed commands; "*" allows any command
+	Dir            string   // Working directory; a step's 'dir' must stay inside it
+	Env            []string // Extra environment variables in KEY=VALUE form
+	AllowEnv       []string // Variable names a step may set through its 'env' input
+	Secrets        []string // Names of host environment variables passed through
+	MaxOutputBytes int      // Limit for captured stdout/stderr (each)
+}
+
+// ShellTool runs a single command without a shell, so arguments are never
+// re-interpreted. The command and every process it started are killed when
+// the step context is cancelled.
+//
+// Inputs:
+//   - command: executable name (must be allowed by config)
+//   - args:    list of arguments
+//   - dir:     working directory, relative to and confined to the configured directory
+//   - env:     map of extra environment variables (names must be in AllowEnv)
+//   - stdin:   data written to standard input
+//   - ignore_exit_code: return the result instead of an error on non-zero exit
+//
+// Output: {stdout, stderr, exit_code, truncated}
+type ShellTool struct {
+	config ShellConfig
+}
+
+// NewShellTool creates a shell tool with the given configuration.
+func NewShellTool(cfg ShellConfig) *ShellTool {
+	return &ShellTool{config: cfg}
+}
+
+func (t *ShellTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	if !t.config.Enabled {
+		return nil, Fatal(fmt.Errorf("shell tool is disabled; set tools.shell.enabled in the workflow to allow running commands"))
+	}
+
+	commandVal, ok := input["command"]
+	if !ok {
+		return nil, InvalidInput("missing required input 'command'")
+	}
+	command, ok := commandVal.(string)
+	if !ok || command == "" {
+		return nil, InvalidInput("'command' must be a non-empty string")
+	}
+	if !t.allowed(command) {
+		return nil, Fatal(fmt.Errorf("command '%s' is not in the shell allowlist", command))
+	}
+
+	args, err := stringList(input["args"])
+	if err != nil {
+		return nil, InvalidInput("'args' %w", err)
+	}
+
+	dir := t.config.Dir
+	if d, ok := input["dir"].(string); ok && d != "" {
+		dir, err = NewWorkspace(t.config.Dir).Resolve(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := t.environment(input["env"])
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.WaitDelay = shellWaitDelay
+	killProcessGroup(cmd)
+	if key := IdempotencyKey(ctx); key != "" {
+		cmd.Env = append(cmd.Env, "FLOE_IDEMPOTENCY_KEY="+key)
+	}
+	if stdin, ok := input["stdin"].(string); ok {
+		cmd.Stdin = bytes.NewBufferString(stdin)
+	}
+
+	limit := t.config.MaxOutputBytes
+	if limit <= 0 {
+		limit = defaultShellMaxOutput
+	}
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &limitedBuffer{limit: limit}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	exitCode := 0
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			return nil, fmt.Errorf("failed to run '%s': %w", command, runErr)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	result := map[string]interface{}{
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+		"exit_code": exitCode,
+		"truncated": stdout.truncated || stderr.truncated,
+	}
+
+	if exitCode != 0 {
+		if ignore, _ := input["ignore_exit_code"].(bool); !ignore {
+			return nil, fmt.Errorf("command '%s' exited with code %d: %s", command, exitCode, truncate(stderr.String(), 200))
+		}
+	}
+	return result, nil
+}
+
+// Idempotent reports false: an arbitrary command may have side effects.
+// Commands can use FLOE_IDEMPOTENCY_KEY to make themselves safe to repeat.
+func (t *ShellTool) Idempotent(input map[string]interface{}) bool {
+	return false
+}
+
+func (t *ShellTool) allowed(command string) bool {
+	for _, a := range t.config.Allow {
+		if a == "*" || a == command {
+			return true
+		}
+	}
+	return false
+}
+
+// environment builds a minimal environment: PATH and HOME from the host, the
+// configured variables, the configured secrets and the step's own 'env' input.
+// A step may only set variables named in AllowEnv, so it cannot override
+// PATH or inject variables such as LD_PRELOAD.
+func (t *ShellTool) environment(extra interface{}) ([]string, error) {
+	env := []string{}
+	for _, key := range []string{"PATH", "HOME"} {
+		if v, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+v)
+		}
+	}
+	env = append(env, t.config.Env...)
+	for _, name := range t.config.Secrets {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	if extra == nil {
+		return env, nil
+	}
+	m, ok := extra.(map[string]interface{})
+	if !ok {
+		return nil, InvalidInput("'env' must be a map, got %T", extra)
+	}
+	for k, v := range m {
+		if !t.envAllowed(k) {
+			return nil, InvalidInput("environment variable '%s' is not in tools.shell.allow_env", k)
+		}
+		env = append(env, fmt.Sprintf("%s=%v", k, v))
+	}
+	return env, nil
+}
+
+func (t *ShellTool) envAllowed(name string) bool {
+	for _, a := range t.config.AllowEnv {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+func stringList(v interface{}) ([]string, error) {
+	switch list := v.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return list, nil
+	case []interface{}:
+		out := make([]string, len(list))
+		for i, item := range list {
+			out[i] = fmt.Sprintf("%v", item)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("must be a list, got %T", v)
+	}
+}
+
+// limitedBuffer keeps at most limit bytes and records whether data was dropped.
+// The buffer is not embedded so io.Copy cannot bypass Write via ReadFrom.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+func init() {
+	Register("shell", NewShellTool(ShellConfig{}))
+}
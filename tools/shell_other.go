@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tools
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups are unavailable; the
+// command itself is still killed on cancellation.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellToolConfinesDir(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewShellTool(ShellConfig{Enabled: true, Allow: []string{"pwd"}, Dir: root})
+
+	out, err := tool.Run(context.Background(), map[string]interface{}{"command": "pwd", "dir": "sub"})
+	if err != nil {
+		t.Fatalf("pwd in sub: %v", err)
+	}
+	realSub, _ := filepath.EvalSymlinks(filepath.Join(root, "sub"))
+	if got := strings.TrimSpace(out.(map[string]interface{})["stdout"].(string)); got != realSub {
+		t.Errorf("pwd = %q, want %q", got, realSub)
+	}
+
+	for _, dir := range []string{"..", "sub/../..", "/", os.TempDir()} {
+		if _, err := tool.Run(context.Background(), map[string]interface{}{"command": "pwd", "dir": dir}); err == nil {
+			t.Errorf("dir %q should be rejected", dir)
+		}
+	}
+}
+
+func TestShellToolRestrictsEnv(t *testing.T) {
+	tool := NewShellTool(ShellConfig{Enabled: true, Allow: []string{"sh"}, AllowEnv: []string{"GREETING"}})
+
+	out, err := tool.Run(context.Background(), map[string]interface{}{
+		"command": "sh",
+		"args":    []interface{}{"-c", "echo $GREETING"},
+		"env":     map[string]interface{}{"GREETING": "hi"},
+	})
+	if err != nil {
+		t.Fatalf("allowed env: %v", err)
+	}
+	if got := out.(map[string]interface{})["stdout"]; got != "hi\n" {
+		t.Errorf("stdout = %q, want %q", got, "hi\n")
+	}
+
+	for _, name := range []string{"PATH", "LD_PRELOAD", "OTHER"} {
+		_, err := tool.Run(context.Background(), map[string]interface{}{
+			"command": "sh",
+			"args":    []interface{}{"-c", "true"},
+			"env":     map[string]interface{}{name: "x"},
+		})
+		if err == nil {
+			t.Errorf("env %s should be rejected", name)
+		}
+	}
+}
+
+func TestShellToolKillsProcessGroupOnCancel(t *testing.T) {
+	tool := NewShellTool(ShellConfig{Enabled: true, Allow: []string{"sh"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The background sleep inherits stdout, so only killing the group (or
+	// WaitDelay) lets Run return.
+	start := time.Now()
+	_, err := tool.Run(ctx, map[string]interface{}{
+		"command": "sh",
+		"args":    []interface{}{"-c", "sleep 30 & sleep 30"},
+	})
+	if err == nil {
+		t.Fatal("cancelled command should fail")
+	}
+	if elapsed := time.Since(start); elapsed > shellWaitDelay {
+		t.Errorf("Run returned after %v, want it to stop promptly", elapsed)
+	}
+}
+
+func TestShellToolRequiresOptIn(t *testing.T) {
+	tool := NewShellTool(ShellConfig{Allow: []string{"*"}})
+	if _, err := tool.Run(context.Background(), map[string]interface{}{"command": "true"}); err == nil {
+		t.Error("disabled shell tool should refuse to run")
+	}
+	tool = NewShellTool(ShellConfig{Enabled: true, Allow: []string{"echo"}})
+	if _, err := tool.Run(context.Background(), map[string]interface{}{"command": "true"}); err == nil {
+		t.Error("command outside the allowlist should be rejected")
+	}
+}
@@ -11,11 +11,11 @@ type SummarizeTool struct{}
 func (t *SummarizeTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
 	textVal, ok := input["text"]
 	if !ok {
-		return nil, fmt.Errorf("missing required input 'text'")
+		return nil, InvalidInput("missing required input 'text'")
 	}
 	text, ok := textVal.(string)
 	if !ok {
-		return nil, fmt.Errorf("'text' must be a string")
+		return nil, InvalidInput("'text' must be a string")
 	}
 
 	// Mock summary: just count words and return a string
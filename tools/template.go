@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs is the function set available to templates. It is limited to
+// pure formatting helpers so templates cannot reach outside their data.
+var TemplateFuncs = template.FuncMap{
+	"join":     templateJoin,
+	"json":     templateJSON,
+	"indent":   templateIndent,
+	"truncate": templateTruncate,
+}
+
+// RenderTemplate renders a Go text/template against data.
+func RenderTemplate(text string, data interface{}) (string, error) {
+	tmpl, err := template.New("template").Funcs(TemplateFuncs).Parse(text)
+	if err != nil {
+		return "", InvalidInput("failed to parse template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// TemplateTool renders the 'template' input. The data defaults to the memory
+// snapshot of the workflow and can be overridden with the 'data' input.
+type TemplateTool struct{}
+
+func (t *TemplateTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	textVal, ok := input["template"]
+	if !ok {
+		return nil, InvalidInput("missing required input 'template'")
+	}
+	text, ok := textVal.(string)
+	if !ok {
+		return nil, InvalidInput("'template' must be a string")
+	}
+
+	var data interface{} = MemoryFromContext(ctx)
+	if d, ok := input["data"]; ok {
+		data = d
+	}
+
+	return RenderTemplate(text, data)
+}
+
+// ReadsMemory reports true unless the data is given explicitly.
+func (t *TemplateTool) ReadsMemory(input map[string]interface{}) bool {
+	_, ok := input["data"]
+	return !ok
+}
+
+func templateJoin(sep string, list interface{}) (string, error) {
+	switch v := list.(type) {
+	case []string:
+		return strings.Join(v, sep), nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprintf("%v", item)
+		}
+		return strings.Join(parts, sep), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+}
+
+func templateJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func templateIndent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func templateTruncate(n int, s string) string {
+	runes := []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
+
+func init() {
+	Register("template", &TemplateTool{})
+}
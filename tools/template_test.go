@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRenderTemplateFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"tags":  []interface{}{"a", 1, true},
+		"names": []string{"x", "y"},
+		"obj":   map[string]interface{}{"k": "v"},
+		"text":  "line1\nline2",
+		"long":  "héllo wörld",
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{`{{join ", " .tags}}`, "a, 1, true"},
+		{`{{join "-" .names}}`, "x-y"},
+		{`{{join "," .missing}}`, ""},
+		{`{{json .obj}}`, `{"k":"v"}`},
+		{`{{json .tags}}`, `["a",1,true]`},
+		{`{{indent 2 .text}}`, "  line1\n  line2"},
+		{`{{indent 0 .text}}`, "line1\nline2"},
+		{`{{truncate 5 .long}}`, "héllo..."},
+		{`{{truncate 11 .long}}`, "héllo wörld"},
+		{`{{truncate 0 .long}}`, "..."},
+		{`{{truncate -1 .long}}`, "héllo wörld"},
+		{`{{truncate 3 "日本語テキスト"}}`, "日本語..."},
+		{`{{.obj.k}} {{len .names}}`, "v 2"},
+	}
+	for _, tt := range tests {
+		got, err := RenderTemplate(tt.tmpl, data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.tmpl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	if _, err := RenderTemplate(`{{if}}`, nil); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("parse error = %v, want invalid input", err)
+	}
+	if _, err := RenderTemplate(`{{env "HOME"}}`, nil); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("unknown func error = %v, want invalid input", err)
+	}
+	if _, err := RenderTemplate(`{{join "," .n}}`, map[string]interface{}{"n": 3}); err == nil || errors.Is(err, ErrInvalidInput) {
+		t.Errorf("join of a number error = %v, want a render error", err)
+	}
+}
+
+func TestTemplateToolData(t *testing.T) {
+	tool := &TemplateTool{}
+	ctx := WithMemory(context.Background(), map[string]interface{}{"name": "memory"})
+
+	out, err := tool.Run(ctx, map[string]interface{}{"template": "hi {{.name}}"})
+	if err != nil || out != "hi memory" {
+		t.Errorf("from memory = %v, %v", out, err)
+	}
+	input := map[string]interface{}{"template": "hi {{.name}}", "data": map[string]interface{}{"name": "data"}}
+	out, err = tool.Run(ctx, input)
+	if err != nil || out != "hi data" {
+		t.Errorf("from data = %v, %v", out, err)
+	}
+
+	if !ReadsMemory(tool, map[string]interface{}{"template": "x"}) || ReadsMemory(tool, input) {
+		t.Error("the tool should read memory only without a data input")
+	}
+	for _, bad := range []map[string]interface{}{{}, {"template": 1}} {
+		if _, err := tool.Run(ctx, bad); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Run(%v) error = %v, want invalid input", bad, err)
+		}
+	}
+}
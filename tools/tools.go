@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"sync"
 )
 
 // Tool is the interface that all tools must implement.
@@ -10,19 +11,58 @@ type Tool interface {
 	Run(ctx context.Context, input map[string]interface{}) (interface{}, error)
 }
 
+// Idempotency is implemented by tools that declare whether repeating a call
+// with the same input is safe. The runtime does not retry non-idempotent calls
+// automatically unless the step allows it. Tools that do not implement it are
+// treated as idempotent.
+type Idempotency interface {
+	Idempotent(input map[string]interface{}) bool
+}
+
+// IsIdempotent reports whether calling the tool again with input is safe.
+func IsIdempotent(tool Tool, input map[string]interface{}) bool {
+	if t, ok := tool.(Idempotency); ok {
+		return t.Idempotent(input)
+	}
+	return true
+}
+
+// MemoryReader is implemented by tools whose output can depend on the memory
+// snapshot in the context as well as on their input. Result caches include
+// the memory in the key of such calls.
+type MemoryReader interface {
+	ReadsMemory(input map[string]interface{}) bool
+}
+
+// ReadsMemory reports whether calling the tool with input reads memory.
+func ReadsMemory(tool Tool, input map[string]interface{}) bool {
+	if t, ok := tool.(MemoryReader); ok {
+		return t.ReadsMemory(input)
+	}
+	return false
+}
+
 // Registry stores available tools.
 var Registry = make(map[string]Tool)
 
+// registryMu guards Registry, since programs embedding floe may register
+// tools while runtimes in other goroutines look them up.
+var registryMu sync.RWMutex
+
 // Register adds a tool to the registry.
 func Register(name string, tool Tool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
 	Registry[name] = tool
 }
 
 // Get retrieves a tool by name.
 func Get(name string) (Tool, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	tool, ok := Registry[name]
 	if !ok {
-		return nil, fmt.Errorf("tool '%s' not found", name)
+		return nil, Fatal(fmt.Errorf("tool '%s' not found", name))
 	}
 	return tool, nil
 }
@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TransformTool selects and reshapes JSON data with a jq-style query.
+//
+// Inputs:
+//   - query:  the query to apply (required)
+//   - source: a JSON document string or an already decoded value
+//   - path:   a memory path to read the value from instead of 'source'
+//
+// A query producing a single result returns it directly; multiple results
+// are returned as an array. A query with no results returns an empty array,
+// so the step's output still overwrites what was stored before.
+type TransformTool struct{}
+
+func (t *TransformTool) Run(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	queryVal, ok := input["query"]
+	if !ok {
+		return nil, InvalidInput("missing required input 'query'")
+	}
+	query, ok := queryVal.(string)
+	if !ok {
+		return nil, InvalidInput("'query' must be a string")
+	}
+
+	data, err := transformSource(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := compileQuery(query)
+	if err != nil {
+		return nil, InvalidInput("invalid query: %w", err)
+	}
+	results, err := f(data)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	switch len(results) {
+	case 0:
+		return []interface{}{}, nil
+	case 1:
+		return results[0], nil
+	default:
+		return results, nil
+	}
+}
+
+// ReadsMemory reports true when the source is read from a memory path.
+func (t *TransformTool) ReadsMemory(input map[string]interface{}) bool {
+	_, ok := input["path"]
+	return ok
+}
+
+func transformSource(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	if pathVal, ok := input["path"]; ok {
+		path, ok := pathVal.(string)
+		if !ok {
+			return nil, InvalidInput("'path' must be a string")
+		}
+		val, err := lookupPath(MemoryFromContext(ctx), path)
+		if err != nil {
+			return nil, err
+		}
+		// A stored JSON string is decoded so tool outputs such as http_get bodies can be queried directly
+		if s, ok := val.(string); ok {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(s), &decoded); err == nil {
+				return decoded, nil
+			}
+		}
+		return normalizeJSON(val)
+	}
+
+	sourceVal, ok := input["source"]
+	if !ok {
+		return nil, InvalidInput("missing required input 'source' or 'path'")
+	}
+	if s, ok := sourceVal.(string); ok {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return decoded, nil
+	}
+	return normalizeJSON(sourceVal)
+}
+
+// normalizeJSON converts arbitrary Go values (e.g. ints from YAML) into the
+// JSON value model the query engine operates on.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("value is not JSON-compatible: %w", err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func lookupPath(data map[string]interface{}, path string) (interface{}, error) {
+	var current interface{} = data
+	for _, k := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot traverse path '%s', segment is not a map", path)
+		}
+		val, exists := m[k]
+		if !exists {
+			return nil, fmt.Errorf("path '%s' not found", path)
+		}
+		current = val
+	}
+	return current, nil
+}
+
+func init() {
+	Register("transform", &TransformTool{})
+}